@@ -0,0 +1,98 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/logger"
+)
+
+/*
+DefaultTimeout is the timeout of commands for which no timeout is configured.
+*/
+const DefaultTimeout = 5 * time.Minute
+
+/*
+Processes started by a command can keep its output open after the command is killed
+if they could not be killed with it. The output is only waited on for this long after the kill.
+*/
+const outputWaitDelay = time.Second
+
+/*
+Run executes a command, killing it if it does not complete within the timeout.
+A timeout of 0 means the command is given the default timeout.
+The standard output and error of the command are logged at the debug level.
+*/
+func Run(timeout time.Duration, log logger.Logger, name string, args ...string) error {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = outputWaitDelay
+	killProcessGroup(cmd)
+
+	cmdLine := strings.Join(append([]string{name}, args...), " ")
+	log.Debugf("Running command: %s", cmdLine)
+	runErr := cmd.Run()
+
+	if stdout.Len() > 0 {
+		log.Debugf("Stdout of command \"%s\":\n%s", cmdLine, strings.TrimRight(stdout.String(), "\n"))
+	}
+	if stderr.Len() > 0 {
+		log.Debugf("Stderr of command \"%s\":\n%s", cmdLine, strings.TrimRight(stderr.String(), "\n"))
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.New(fmt.Sprintf("Command \"%s\" did not complete within the timeout of %s", cmdLine, timeout.String()))
+	}
+
+	if runErr != nil {
+		return errors.New(fmt.Sprintf("Command \"%s\" failed: %s: %s", cmdLine, runErr.Error(), strings.TrimSpace(stderr.String())))
+	}
+
+	return nil
+}
+
+/*
+RunShell executes a command line with the given shell, in the same way Run does.
+The shell defaults to /bin/sh if it is empty.
+*/
+func RunShell(timeout time.Duration, log logger.Logger, shell string, cmdLine string) error {
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+
+	return Run(timeout, log, shell, "-c", cmdLine)
+}
+
+/*
+Render generates a command line from a go template and the data to fill it with.
+*/
+func Render(name string, tmpl string, data interface{}) (string, error) {
+	parsed, parseErr := template.New(name).Option("missingkey=error").Parse(tmpl)
+	if parseErr != nil {
+		return "", errors.New(fmt.Sprintf("Error parsing the \"%s\" command template: %s", name, parseErr.Error()))
+	}
+
+	var cmdLine bytes.Buffer
+	execErr := parsed.Execute(&cmdLine, data)
+	if execErr != nil {
+		return "", errors.New(fmt.Sprintf("Error rendering the \"%s\" command template: %s", name, execErr.Error()))
+	}
+
+	return cmdLine.String(), nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package command
+
+import (
+	"os/exec"
+)
+
+/*
+killProcessGroup is not supported on this platform. Only the command itself is killed
+and its output stops being waited on after the wait delay.
+*/
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"time"
 	"net/url"
@@ -42,32 +43,94 @@ func (conf *PgClientConfig) GetConnStr() string {
 	return conn
 }
 
-type CertAuth struct {
-	CaCert     string `yaml:"ca_cert"`
-	ClientCert string `yaml:"client_cert"`
-	ClientKey  string `yaml:"client_key"`
+type PatroniClientAuthConfig struct {
+	CaCert       string `yaml:"ca_cert"`
+	ClientCert   string `yaml:"client_cert"`
+	ClientKey    string `yaml:"client_key"`
+	PasswordAuth string `yaml:"password_auth"`
+	Username     string `yaml:"-"`
+	Password     string `yaml:"-"`
+}
+
+type HealthConfig struct {
+	MaxLag             *int64   `yaml:"max_lag"`
+	RequireSyncStandby bool     `yaml:"require_sync_standby"`
+	SameTimeline       bool     `yaml:"same_timeline"`
+	NoPendingRestart   bool     `yaml:"no_pending_restart"`
+	LeaderLocations    []string `yaml:"leader_locations"`
 }
 
 type PatroniClientConfig struct {
 	Endpoint          string
-	Auth              CertAuth
+	Endpoints         []string
+	DiscoverEndpoints bool `yaml:"discover_endpoints"`
+	Scheme            string
+	Auth              PatroniClientAuthConfig
 	ConnectionTimeout time.Duration `yaml:"connection_timeout"`
 	RequestTimeout    time.Duration `yaml:"request_timeout"`
+	Health            HealthConfig
 }
 
+/*
+Scheduled switchovers need to be far enough ahead for patroni not to refuse them
+as being scheduled in the past by the time it receives them.
+*/
+const MinSwitchoverScheduleDelay = 5 * time.Second
+
+/*
+The counts of the tests run the built-in scenarios of the scenario package.
+New tests are expected to be defined as scenarios rather than as new counts.
+*/
 type TestsConfig struct {
-	Switchovers          int64
-	LeaderLosses         int64         `yaml:"leader_losses"`
-	SyncStanbyLosses     int64         `yaml:"sync_standby_losses"`
-	LeaderReboots        int64         `yaml:"leader_reboots"`
-	SyncStanbyReboots    int64         `yaml:"sync_standby_reboots"`
-	ClusterReboots       int64         `yaml:"cluster_reboots"`
-	ValidationInterval   time.Duration `yaml:"validation_interval"`
-	ChangeRecoverTimeout time.Duration `yaml:"change_recover_timeout"`
-	LossRecoverTimeout   time.Duration `yaml:"loss_recover_timeout"`
-	RebootRecoverTimeout time.Duration `yaml:"reboot_recover_timeout"`
-	RebuildPause         time.Duration `yaml:"rebuild_pause"`
-	RestartPause         time.Duration `yaml:"restart_pause"`
+	Switchovers                 int64
+	Failovers                   int64
+	ScheduledSwitchovers        int64         `yaml:"scheduled_switchovers"`
+	LeaderLosses                int64         `yaml:"leader_losses"`
+	SyncStanbyLosses            int64         `yaml:"sync_standby_losses"`
+	LeaderReboots               int64         `yaml:"leader_reboots"`
+	SyncStanbyReboots           int64         `yaml:"sync_standby_reboots"`
+	ClusterReboots              int64         `yaml:"cluster_reboots"`
+	AsyncReplicaLosses          int64         `yaml:"async_replica_losses"`
+	AsyncReplicaReboots         int64         `yaml:"async_replica_reboots"`
+	LeaderAndSyncStandbyLosses  int64         `yaml:"leader_and_sync_standby_losses"`
+	LeaderAndSyncStandbyReboots int64         `yaml:"leader_and_sync_standby_reboots"`
+	ReplicasLosses              int64         `yaml:"replicas_losses"`
+	ReplicasReboots             int64         `yaml:"replicas_reboots"`
+	LeaderPartitions            int64         `yaml:"leader_partitions"`
+	LeaderDegradations          int64         `yaml:"leader_degradations"`
+	SyncStandbyDegradations     int64         `yaml:"sync_standby_degradations"`
+	LeaderPostgresKills         int64         `yaml:"leader_postgres_kills"`
+	SyncStandbyPostgresKills    int64         `yaml:"sync_standby_postgres_kills"`
+	LeaderPatroniKills          int64         `yaml:"leader_patroni_kills"`
+	SyncStandbyPatroniKills     int64         `yaml:"sync_standby_patroni_kills"`
+	PausedLeaderReboots         int64         `yaml:"paused_leader_reboots"`
+	PausedLeaderPostgresKills   int64         `yaml:"paused_leader_postgres_kills"`
+	LeaderPostgresRestarts      int64         `yaml:"leader_postgres_restarts"`
+	SyncStandbyPostgresRestarts int64         `yaml:"sync_standby_postgres_restarts"`
+	SyncStandbyReinits          int64         `yaml:"sync_standby_reinits"`
+	AsyncReplicaReinits         int64         `yaml:"async_replica_reinits"`
+	ValidationInterval          time.Duration `yaml:"validation_interval"`
+	SwitchoverScheduleDelay     time.Duration `yaml:"switchover_schedule_delay"`
+	ChangeRecoverTimeout        time.Duration `yaml:"change_recover_timeout"`
+	LossRecoverTimeout          time.Duration `yaml:"loss_recover_timeout"`
+	RebootRecoverTimeout        time.Duration `yaml:"reboot_recover_timeout"`
+	RebuildPause                time.Duration `yaml:"rebuild_pause"`
+	RestartPause                time.Duration `yaml:"restart_pause"`
+	PartitionDuration           time.Duration `yaml:"partition_duration"`
+	PartitionRecoverTimeout     time.Duration `yaml:"partition_recover_timeout"`
+	DegradationLatency          time.Duration `yaml:"degradation_latency"`
+	DegradationJitter           time.Duration `yaml:"degradation_jitter"`
+	DegradationPacketLoss       float64       `yaml:"degradation_packet_loss"`
+	DegradationDuration         time.Duration `yaml:"degradation_duration"`
+	DegradationRecoverTimeout   time.Duration `yaml:"degradation_recover_timeout"`
+	KillPause                   time.Duration `yaml:"kill_pause"`
+	RestartPendingOnly          bool          `yaml:"restart_pending_only"`
+	RestartRole                 string        `yaml:"restart_role"`
+	RestartRecoverTimeout       time.Duration `yaml:"restart_recover_timeout"`
+	ReinitRecoverTimeout        time.Duration `yaml:"reinit_recover_timeout"`
+	PauseObservation            time.Duration `yaml:"pause_observation"`
+	KillRecoverTimeout          time.Duration `yaml:"kill_recover_timeout"`
+	Tester                      TesterConfig
 }
 
 type TerraformConfig struct {
@@ -75,12 +138,143 @@ type TerraformConfig struct {
 	ClusterFile string `yaml:"cluster_file"`
 }
 
+type DockerConfig struct {
+	Binary         string
+	ComposeFile    string            `yaml:"compose_file"`
+	ComposeProject string            `yaml:"compose_project"`
+	Containers     map[string]string
+	Services       map[string]string
+	CommandTimeout time.Duration     `yaml:"command_timeout"`
+}
+
+type ShellConfig struct {
+	Shell    string
+	Destroy  string
+	Recreate string
+	Stop     string
+	Start    string
+	Timeout  time.Duration
+}
+
+type DisruptionConfig struct {
+	Backend string
+	Docker  DockerConfig
+	Shell   ShellConfig
+}
+
+type HooksConfig struct {
+	Shell          string
+	Timeout        time.Duration
+	DcsHosts       []string `yaml:"dcs_hosts"`
+	Partition      string
+	Heal           string
+	Degrade        string
+	RestoreNetwork string `yaml:"restore_network"`
+	KillPostgres   string `yaml:"kill_postgres"`
+	KillPatroni    string `yaml:"kill_patroni"`
+}
+
+type SqlTesterConfig struct {
+	Initialize string
+	Run        string
+	Verify     string
+	Cleanup    string
+}
+
+func resolvePath(dir string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(dir, path)
+}
+
+/*
+resolvePaths makes the paths of the sql files relative to the given directory.
+*/
+func (s *SqlTesterConfig) resolvePaths(dir string) {
+	s.Initialize = resolvePath(dir, s.Initialize)
+	s.Run = resolvePath(dir, s.Run)
+	s.Verify = resolvePath(dir, s.Verify)
+	s.Cleanup = resolvePath(dir, s.Cleanup)
+}
+
+type TesterConfig struct {
+	Kind    string
+	Table   string
+	Workers int64
+	Sql     SqlTesterConfig
+}
+
+/*
+UnmarshalYAML allows a tester to be configured with only its kind, as a string, when its other fields can be defaulted.
+*/
+func (t *TesterConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var kind string
+	if unmarshal(&kind) == nil {
+		t.Kind = kind
+		return nil
+	}
+
+	type testerConfig TesterConfig
+	return unmarshal((*testerConfig)(t))
+}
+
+type ScenarioAssertConfig struct {
+	LeaderChanged *bool         `yaml:"leader_changed"`
+	MaxLostOps    *int64        `yaml:"max_lost_ops"`
+	MaxGhostOps   *int64        `yaml:"max_ghost_ops"`
+	MaxOutage     time.Duration `yaml:"max_outage"`
+}
+
+type ScenarioStepConfig struct {
+	Action                string
+	Target                string
+	Targets               []string
+	Disruption            string
+	Duration              time.Duration
+	Timeout               time.Duration
+	Delay                 time.Duration
+	SynchronousMode       *bool                `yaml:"synchronous_mode"`
+	SynchronousModeStrict *bool                `yaml:"synchronous_mode_strict"`
+	Assert                ScenarioAssertConfig `yaml:",inline"`
+}
+
+type ScenarioConfig struct {
+	Name       string
+	Tester     TesterConfig
+	Iterations int64
+	Steps      []ScenarioStepConfig
+}
+
+type ChaosConfig struct {
+	Duration    time.Duration
+	Tester      TesterConfig
+	Disruptions []string
+	Targets     []string
+	MinPause    time.Duration `yaml:"min_pause"`
+	MaxPause    time.Duration `yaml:"max_pause"`
+	MinInterval time.Duration `yaml:"min_interval"`
+	MaxInterval time.Duration `yaml:"max_interval"`
+}
+
+type TimelineConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval"`
+	LagThreshold int64         `yaml:"lag_threshold"`
+}
+
 type Config struct {
 	PgClient      PgClientConfig      `yaml:"postgres_client"`
 	PatroniClient PatroniClientConfig `yaml:"patroni_client"`
 	LogLevel      string              `yaml:"log_level"`
 	Tests         TestsConfig
 	Terraform     TerraformConfig
+	Disruption    DisruptionConfig
+	Hooks         HooksConfig
+	Scenarios     []ScenarioConfig
+	Chaos         ChaosConfig
+	Timeline      TimelineConfig
+	Seed          int64
 }
 
 func (c *Config) GetLogLevel() int64 {
@@ -133,5 +327,34 @@ func GetConfig(path string) (Config, error) {
 	c.PgClient.Auth.Username = pAuth.Username
 	c.PgClient.Auth.Password = pAuth.Password
 
+	if c.PatroniClient.Auth.PasswordAuth != "" {
+		patrAuth, patrAuthErr := GetPasswordAuth(c.PatroniClient.Auth.PasswordAuth)
+		if patrAuthErr != nil {
+			return c, patrAuthErr
+		}
+		c.PatroniClient.Auth.Username = patrAuth.Username
+		c.PatroniClient.Auth.Password = patrAuth.Password
+	}
+
+	//Sql files are relative to the configuration file so that a configuration can be run from any directory
+	dir := filepath.Dir(path)
+	for idx := range c.Scenarios {
+		c.Scenarios[idx].Tester.Sql.resolvePaths(dir)
+	}
+	c.Chaos.Tester.Sql.resolvePaths(dir)
+	c.Tests.Tester.Sql.resolvePaths(dir)
+
+	if c.Tests.ScheduledSwitchovers > 0 && c.Tests.SwitchoverScheduleDelay < MinSwitchoverScheduleDelay {
+		return c, errors.New(fmt.Sprintf("The switchover schedule delay must be at least %s", MinSwitchoverScheduleDelay.String()))
+	}
+
+	for _, scen := range c.Scenarios {
+		for idx, step := range scen.Steps {
+			if strings.ToLower(step.Action) == "switchover" && step.Delay > 0 && step.Delay < MinSwitchoverScheduleDelay {
+				return c, errors.New(fmt.Sprintf("The delay of switchover step %d of scenario \"%s\" must be at least %s", idx+1, scen.Name, MinSwitchoverScheduleDelay.String()))
+			}
+		}
+	}
+
 	return c, nil
 }
@@ -0,0 +1,38 @@
+package disruption
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/config"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/docker"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/logger"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/patroni"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/shell"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/terraform"
+)
+
+/*
+Disruptor breaks and restores patroni members. Each method applies to all the
+members it is passed at once, which is how the entire cluster is targeted.
+*/
+type Disruptor interface {
+	Destroy(members []patroni.PatroniMember) error
+	Recreate(members []patroni.PatroniMember) error
+	Stop(members []patroni.PatroniMember) error
+	Start(members []patroni.PatroniMember) error
+}
+
+func NewDisruptor(conf *config.Config, log logger.Logger) (Disruptor, error) {
+	switch strings.ToLower(conf.Disruption.Backend) {
+	case "", "terraform":
+		return &terraform.Disruptor{Conf: &conf.Terraform, Log: log}, nil
+	case "docker":
+		return &docker.Disruptor{Conf: &conf.Disruption.Docker, Log: log}, nil
+	case "shell":
+		return &shell.Disruptor{Conf: &conf.Disruption.Shell, Log: log}, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("Unsupported disruption backend \"%s\"", conf.Disruption.Backend))
+	}
+}
@@ -0,0 +1,87 @@
+package docker
+
+import (
+	"strings"
+
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/command"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/config"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/logger"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/patroni"
+)
+
+type Disruptor struct {
+	Conf *config.DockerConfig
+	Log  logger.Logger
+}
+
+func (dis *Disruptor) binary() string {
+	if dis.Conf.Binary == "" {
+		return "docker"
+	}
+
+	return dis.Conf.Binary
+}
+
+func (dis *Disruptor) containers(members []patroni.PatroniMember) []string {
+	containers := []string{}
+	for _, member := range members {
+		if container, ok := dis.Conf.Containers[member.Name]; ok {
+			containers = append(containers, container)
+			continue
+		}
+		containers = append(containers, member.Name)
+	}
+	return containers
+}
+
+func (dis *Disruptor) services(members []patroni.PatroniMember) []string {
+	services := []string{}
+	for _, member := range members {
+		if service, ok := dis.Conf.Services[member.Name]; ok {
+			services = append(services, service)
+			continue
+		}
+		services = append(services, member.Name)
+	}
+	return services
+}
+
+func (dis *Disruptor) runOnContainers(action string, args []string, containers []string) error {
+	err := command.Run(dis.Conf.CommandTimeout, dis.Log, dis.binary(), append(args, containers...)...)
+	if err != nil {
+		return err
+	}
+
+	if len(containers) == 1 {
+		dis.Log.Infof("Container \"%s\" has been %s", containers[0], action)
+	} else {
+		dis.Log.Infof("Containers \"%s\" have been %s", strings.Join(containers, "\", \""), action)
+	}
+
+	return nil
+}
+
+func (dis *Disruptor) Destroy(members []patroni.PatroniMember) error {
+	return dis.runOnContainers("destroyed", []string{"rm", "--force", "--volumes"}, dis.containers(members))
+}
+
+func (dis *Disruptor) Recreate(members []patroni.PatroniMember) error {
+	args := []string{"compose"}
+	if dis.Conf.ComposeFile != "" {
+		args = append(args, "--file", dis.Conf.ComposeFile)
+	}
+	if dis.Conf.ComposeProject != "" {
+		args = append(args, "--project-name", dis.Conf.ComposeProject)
+	}
+	args = append(args, "up", "--detach", "--no-deps")
+
+	return dis.runOnContainers("recreated", args, dis.services(members))
+}
+
+func (dis *Disruptor) Stop(members []patroni.PatroniMember) error {
+	return dis.runOnContainers("stopped", []string{"stop"}, dis.containers(members))
+}
+
+func (dis *Disruptor) Start(members []patroni.PatroniMember) error {
+	return dis.runOnContainers("started", []string{"start"}, dis.containers(members))
+}
@@ -0,0 +1,161 @@
+package hooks
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/command"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/config"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/logger"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/patroni"
+)
+
+type Degradation struct {
+	Latency    time.Duration
+	Jitter     time.Duration
+	PacketLoss float64
+}
+
+type TemplateData struct {
+	Name     string
+	Host     string
+	Peers    []string
+	DcsHosts []string
+	Degradation
+}
+
+/*
+Hooks runs the configured command templates for the disruptions that are
+not managed by the disruption backend.
+*/
+type Hooks struct {
+	Conf *config.HooksConfig
+	Log  logger.Logger
+}
+
+func (hks *Hooks) runOnMember(hook string, tmpl string, member patroni.PatroniMember, cluster patroni.PatroniCluster, degradation Degradation) error {
+	data := TemplateData{
+		Name:        member.Name,
+		Host:        member.Host,
+		Peers:       []string{},
+		DcsHosts:    hks.Conf.DcsHosts,
+		Degradation: degradation,
+	}
+	for _, peer := range cluster.Members {
+		if peer.Name != member.Name {
+			data.Peers = append(data.Peers, peer.Host)
+		}
+	}
+
+	cmdLine, renderErr := command.Render(hook, tmpl, data)
+	if renderErr != nil {
+		return renderErr
+	}
+
+	return command.RunShell(hks.Conf.Timeout, hks.Log, hks.Conf.Shell, cmdLine)
+}
+
+func (hks *Hooks) run(hook string, tmpl string, members []patroni.PatroniMember, cluster patroni.PatroniCluster, degradation Degradation) error {
+	if tmpl == "" {
+		return errors.New(fmt.Sprintf("No command is configured for the \"%s\" hook", hook))
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(members))
+	for idx, member := range members {
+		wg.Add(1)
+		go func(idx int, member patroni.PatroniMember) {
+			defer wg.Done()
+			errs[idx] = hks.runOnMember(hook, tmpl, member, cluster, degradation)
+		}(idx, member)
+	}
+	wg.Wait()
+
+	for idx, member := range members {
+		if errs[idx] != nil {
+			return errors.New(fmt.Sprintf("Error running the \"%s\" hook on server \"%s\": %s", hook, member.Name, errs[idx].Error()))
+		}
+	}
+
+	return nil
+}
+
+/*
+Partition cuts each of the members off from the dcs and from the other cluster members.
+*/
+func (hks *Hooks) Partition(members []patroni.PatroniMember, cluster patroni.PatroniCluster) error {
+	err := hks.run("partition", hks.Conf.Partition, members, cluster, Degradation{})
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		hks.Log.Infof("Server \"%s\" has been partitioned from the dcs and the other members", member.Name)
+	}
+	return nil
+}
+
+func (hks *Hooks) Heal(members []patroni.PatroniMember, cluster patroni.PatroniCluster) error {
+	err := hks.run("heal", hks.Conf.Heal, members, cluster, Degradation{})
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		hks.Log.Infof("Partition of server \"%s\" has been healed", member.Name)
+	}
+	return nil
+}
+
+/*
+Degrade applies the latency, jitter and packet loss of the degradation to the network of the members.
+*/
+func (hks *Hooks) Degrade(members []patroni.PatroniMember, cluster patroni.PatroniCluster, degradation Degradation) error {
+	err := hks.run("degrade", hks.Conf.Degrade, members, cluster, degradation)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		hks.Log.Infof("Network of server \"%s\" has been degraded with %s latency, %s jitter and %g%% packet loss", member.Name, degradation.Latency.String(), degradation.Jitter.String(), degradation.PacketLoss)
+	}
+	return nil
+}
+
+func (hks *Hooks) RestoreNetwork(members []patroni.PatroniMember, cluster patroni.PatroniCluster) error {
+	err := hks.run("restore_network", hks.Conf.RestoreNetwork, members, cluster, Degradation{})
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		hks.Log.Infof("Network of server \"%s\" has been restored", member.Name)
+	}
+	return nil
+}
+
+func (hks *Hooks) KillPostgres(members []patroni.PatroniMember, cluster patroni.PatroniCluster) error {
+	err := hks.run("kill_postgres", hks.Conf.KillPostgres, members, cluster, Degradation{})
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		hks.Log.Infof("Postgres postmaster of server \"%s\" has been killed", member.Name)
+	}
+	return nil
+}
+
+func (hks *Hooks) KillPatroni(members []patroni.PatroniMember, cluster patroni.PatroniCluster) error {
+	err := hks.run("kill_patroni", hks.Conf.KillPatroni, members, cluster, Degradation{})
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		hks.Log.Infof("Patroni process of server \"%s\" has been killed", member.Name)
+	}
+	return nil
+}
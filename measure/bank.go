@@ -0,0 +1,192 @@
+package measure
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/config"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/random"
+)
+
+const bankAccounts = int64(10)
+const bankInitialBalance = int64(1000)
+
+/*
+Bank transfers random amounts between accounts, each transfer debiting and crediting
+the accounts in separate statements of the same transaction. The total balance is
+expected to be conserved and no account is expected to ever go negative.
+*/
+type Bank struct {
+	TableName  string
+	violations violationTracker
+	rnd        *rand.Rand
+}
+
+func (bank *Bank) Initialize(conf *config.PgClientConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	defer cancel()
+	conn, connErr := pgx.Connect(ctx, conf.GetConnStr())
+	if connErr != nil {
+		return connErr
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		defer cancel()
+		conn.Close(ctx)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	tx, txErr := conn.Begin(ctx)
+	if txErr != nil {
+		return txErr
+	}
+
+	_, txErr = tx.Exec(ctx, fmt.Sprintf("CREATE TABLE %s (id bigint PRIMARY KEY, balance bigint NOT NULL);", bank.TableName))
+	if txErr != nil {
+		return txErr
+	}
+
+	_, txErr = tx.Exec(ctx, fmt.Sprintf("INSERT INTO %s (id, balance) SELECT generate_series(1, $1), $2;", bank.TableName), bankAccounts, bankInitialBalance)
+	if txErr != nil {
+		return txErr
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	return tx.Commit(ctx)
+}
+
+func (bank *Bank) Run(conf *config.PgClientConfig) (Anomaly, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	defer cancel()
+	conn, connErr := pgx.Connect(ctx, conf.GetConnStr())
+	if connErr != nil {
+		return NoProblem, connErr
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		defer cancel()
+		conn.Close(ctx)
+	}()
+
+	if bank.rnd == nil {
+		bank.rnd = random.New()
+	}
+
+	from := bank.rnd.Int63n(bankAccounts) + 1
+	to := bank.rnd.Int63n(bankAccounts-1) + 1
+	if to >= from {
+		to += 1
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	tx, txErr := conn.Begin(ctx)
+	if txErr != nil {
+		return NoProblem, txErr
+	}
+
+	var balance int64
+	txErr = tx.QueryRow(ctx, fmt.Sprintf("SELECT balance FROM %s WHERE id = $1 FOR UPDATE;", bank.TableName), from).Scan(&balance)
+	if txErr != nil {
+		return NoProblem, txErr
+	}
+
+	amount := int64(0)
+	if balance > 0 {
+		amount = bank.rnd.Int63n(balance) + 1
+	}
+
+	_, txErr = tx.Exec(ctx, fmt.Sprintf("UPDATE %s SET balance = balance - $1 WHERE id = $2;", bank.TableName), amount, from)
+	if txErr != nil {
+		return NoProblem, txErr
+	}
+
+	_, txErr = tx.Exec(ctx, fmt.Sprintf("UPDATE %s SET balance = balance + $1 WHERE id = $2;", bank.TableName), amount, to)
+	if txErr != nil {
+		return NoProblem, txErr
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	return NoProblem, tx.Commit(ctx)
+}
+
+/*
+Reconcile checks that the accounts are all there, that their total balance was conserved
+and that none of them has a negative balance. Violations that persist from the previous
+reconciliation are not reported again.
+*/
+func (bank *Bank) Reconcile(conf *config.PgClientConfig) (Reconciliation, error) {
+	var rec Reconciliation
+
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	defer cancel()
+	conn, connErr := pgx.Connect(ctx, conf.GetConnStr())
+	if connErr != nil {
+		return rec, connErr
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		defer cancel()
+		conn.Close(ctx)
+	}()
+
+	var accounts, total, negatives int64
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	queryErr := conn.QueryRow(
+		ctx,
+		fmt.Sprintf("SELECT count(*), coalesce(sum(balance), 0), count(*) FILTER (WHERE balance < 0) FROM %s;", bank.TableName),
+	).Scan(&accounts, &total, &negatives)
+	if queryErr != nil {
+		return rec, queryErr
+	}
+
+	violations := []string{}
+	if accounts != bankAccounts {
+		violations = append(violations, fmt.Sprintf("%d accounts were found instead of %d", accounts, bankAccounts))
+	}
+
+	if total != bankAccounts*bankInitialBalance {
+		violations = append(violations, fmt.Sprintf("Total balance is %d instead of %d", total, bankAccounts*bankInitialBalance))
+	}
+
+	if negatives > 0 {
+		violations = append(violations, fmt.Sprintf("%d accounts have a negative balance", negatives))
+	}
+
+	rec.Violations = bank.violations.newViolations(violations)
+	return rec, nil
+}
+
+func (bank *Bank) Cleanup(conf *config.PgClientConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	defer cancel()
+	conn, connErr := pgx.Connect(ctx, conf.GetConnStr())
+	if connErr != nil {
+		return connErr
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		defer cancel()
+		conn.Close(ctx)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	_, execErr := conn.Exec(ctx, fmt.Sprintf("DROP TABLE %s;", bank.TableName))
+	return execErr
+}
+
+func (bank *Bank) Id() string {
+	return fmt.Sprintf("Bank on %s", bank.TableName)
+}
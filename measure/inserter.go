@@ -0,0 +1,215 @@
+package measure
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/config"
+)
+
+/*
+Inserter inserts a new row for each operation, identified by the index of the operation.
+It keeps track of which commits were acknowledged and reconciles them with the rows that
+are actually in the table to find exactly which commits were lost or ghosted.
+Commits that were found in the table are only kept as a count, along with the period
+they were committed in, to detect their loss by a later disruption.
+*/
+type Inserter struct {
+	TableName       string
+	index           int64
+	acked           map[int64]time.Time
+	unacked         map[int64]time.Time
+	confirmed       int64
+	confirmedWindow Window
+}
+
+func (ins *Inserter) Initialize(conf *config.PgClientConfig) error {
+	ins.acked = map[int64]time.Time{}
+	ins.unacked = map[int64]time.Time{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	defer cancel()
+	conn, connErr := pgx.Connect(ctx, conf.GetConnStr())
+	if connErr != nil {
+		return connErr
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		defer cancel()
+		conn.Close(ctx)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	_, execErr := conn.Exec(ctx, fmt.Sprintf("CREATE TABLE %s (id bigint PRIMARY KEY, inserted_at timestamptz NOT NULL DEFAULT now());", ins.TableName))
+	return execErr
+}
+
+func (ins *Inserter) Run(conf *config.PgClientConfig) (Anomaly, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	defer cancel()
+	conn, connErr := pgx.Connect(ctx, conf.GetConnStr())
+	if connErr != nil {
+		return NoProblem, connErr
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		defer cancel()
+		conn.Close(ctx)
+	}()
+
+	id := ins.index
+	ins.index += 1
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	tx, txErr := conn.Begin(ctx)
+	if txErr != nil {
+		return NoProblem, txErr
+	}
+
+	_, txErr = tx.Exec(ctx, fmt.Sprintf("INSERT INTO %s (id) VALUES ($1);", ins.TableName), id)
+	if txErr != nil {
+		return NoProblem, txErr
+	}
+
+	//From here, the commit may or may not be applied if an error is returned
+	committedAt := time.Now()
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	commErr := tx.Commit(ctx)
+	if commErr != nil {
+		ins.unacked[id] = committedAt
+		return NoProblem, commErr
+	}
+
+	ins.acked[id] = committedAt
+	return NoProblem, nil
+}
+
+func (ins *Inserter) presentIds(conf *config.PgClientConfig) (map[int64]bool, error) {
+	present := map[int64]bool{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	defer cancel()
+	conn, connErr := pgx.Connect(ctx, conf.GetConnStr())
+	if connErr != nil {
+		return present, connErr
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		defer cancel()
+		conn.Close(ctx)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	rows, queryErr := conn.Query(ctx, fmt.Sprintf("SELECT id FROM %s;", ins.TableName))
+	if queryErr != nil {
+		return present, queryErr
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		scanErr := rows.Scan(&id)
+		if scanErr != nil {
+			return present, scanErr
+		}
+		present[id] = true
+	}
+
+	return present, rows.Err()
+}
+
+func addToWindow(window *Window, at time.Time) {
+	if window.Start.IsZero() || at.Before(window.Start) {
+		window.Start = at
+	}
+	if at.After(window.End) {
+		window.End = at
+	}
+}
+
+/*
+Reconcile compares the acknowledged and unacknowledged commits with the rows in the table.
+Acknowledged commits that are missing are lost and unacknowledged commits that are present are ghosts.
+Commits are settled once reconciled. The commits that were found in the table are confirmed and
+a decrease in their number is reported as lost commits from the period the confirmed commits span.
+*/
+func (ins *Inserter) Reconcile(conf *config.PgClientConfig) (Reconciliation, error) {
+	var rec Reconciliation
+
+	present, presentErr := ins.presentIds(conf)
+	if presentErr != nil {
+		return rec, presentErr
+	}
+
+	stillConfirmed := int64(0)
+	for id := range present {
+		_, isAcked := ins.acked[id]
+		_, isUnacked := ins.unacked[id]
+		if !isAcked && !isUnacked {
+			stillConfirmed += 1
+		}
+	}
+
+	if stillConfirmed < ins.confirmed {
+		rec.LostOps += ins.confirmed - stillConfirmed
+		addToWindow(&rec.LostWrites, ins.confirmedWindow.Start)
+		addToWindow(&rec.LostWrites, ins.confirmedWindow.End)
+	}
+	ins.confirmed = stillConfirmed
+
+	for id, committedAt := range ins.acked {
+		if present[id] {
+			ins.confirmed += 1
+			addToWindow(&ins.confirmedWindow, committedAt)
+		} else {
+			rec.LostOps += 1
+			addToWindow(&rec.LostWrites, committedAt)
+		}
+		delete(ins.acked, id)
+	}
+
+	for id, committedAt := range ins.unacked {
+		if present[id] {
+			rec.GhostOps += 1
+			ins.confirmed += 1
+			addToWindow(&ins.confirmedWindow, committedAt)
+		}
+		delete(ins.unacked, id)
+	}
+
+	return rec, nil
+}
+
+func (ins *Inserter) Cleanup(conf *config.PgClientConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	defer cancel()
+	conn, connErr := pgx.Connect(ctx, conf.GetConnStr())
+	if connErr != nil {
+		return connErr
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		defer cancel()
+		conn.Close(ctx)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	_, execErr := conn.Exec(ctx, fmt.Sprintf("DROP TABLE %s;", ins.TableName))
+	return execErr
+}
+
+func (ins *Inserter) Id() string {
+	return fmt.Sprintf("Inserter on %s", ins.TableName)
+}
@@ -1,8 +1,11 @@
 package measure
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/config"
@@ -15,15 +18,174 @@ type Outages struct {
 	Longest       time.Duration
 }
 
+type Latency struct {
+	Average time.Duration
+	Median  time.Duration
+	P99     time.Duration
+	Max     time.Duration
+}
+
+type Performance struct {
+	SuccessfulOps int64
+	Throughput    float64
+	Latency       Latency
+}
+
+func (perf *Performance) Lines() []string {
+	return []string{
+		fmt.Sprintf("Successful Ops: %d", perf.SuccessfulOps),
+		fmt.Sprintf("Throughput: %.2f ops/s", perf.Throughput),
+		fmt.Sprintf("Latency:"),
+		fmt.Sprintf("\tAverage: %s", perf.Latency.Average.String()),
+		fmt.Sprintf("\tMedian: %s", perf.Latency.Median.String()),
+		fmt.Sprintf("\t99th Percentile: %s", perf.Latency.P99.String()),
+		fmt.Sprintf("\tMax: %s", perf.Latency.Max.String()),
+	}
+}
+
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+type opSample struct {
+	start   time.Time
+	latency time.Duration
+}
+
+/*
+Violation is a consistency invariant that a tester found broken.
+*/
+type Violation struct {
+	Time        time.Time
+	Description string
+}
+
 type Measurements struct {
-	TotalOps int64
-	LostOps  int64
-	GhostOps int64
-	Outages  Outages
+	TotalOps              int64
+	LostOps               int64
+	GhostOps              int64
+	SerializationFailures int64
+	Outages               Outages
+	Window                Window
+	Workers               []Measurements
+	LostWrites            []Window
+	Violations            []Violation
+	samples               []opSample
+	outages               []Window
+}
+
+/*
+mergeWindows merges the overlapping windows together and returns them sorted by start time.
+*/
+func mergeWindows(windows []Window) []Window {
+	sorted := append([]Window{}, windows...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+
+	merged := []Window{}
+	for _, window := range sorted {
+		last := len(merged) - 1
+		if last >= 0 && !window.Start.After(merged[last].End) {
+			if window.End.After(merged[last].End) {
+				merged[last].End = window.End
+			}
+			continue
+		}
+		merged = append(merged, window)
+	}
+
+	return merged
+}
+
+/*
+aggregate combines the measurements of concurrent workers. The outages of the aggregate are the
+periods during which at least one worker experienced an outage that ended before the measure completed.
+*/
+func aggregate(workers []Measurements) Measurements {
+	var meas Measurements
+	outages := []Window{}
+	for _, worker := range workers {
+		meas.TotalOps += worker.TotalOps
+		meas.LostOps += worker.LostOps
+		meas.GhostOps += worker.GhostOps
+		meas.SerializationFailures += worker.SerializationFailures
+		if !worker.Window.Start.IsZero() && (meas.Window.Start.IsZero() || worker.Window.Start.Before(meas.Window.Start)) {
+			meas.Window.Start = worker.Window.Start
+		}
+		if worker.Window.End.After(meas.Window.End) {
+			meas.Window.End = worker.Window.End
+		}
+		meas.LostWrites = append(meas.LostWrites, worker.LostWrites...)
+		meas.Violations = append(meas.Violations, worker.Violations...)
+		meas.samples = append(meas.samples, worker.samples...)
+		outages = append(outages, worker.outages...)
+	}
+
+	meas.outages = mergeWindows(outages)
+	for _, outage := range meas.outages {
+		duration := outage.End.Sub(outage.Start)
+		meas.Outages.Count += 1
+		meas.Outages.TotalDuration += duration
+		if duration > meas.Outages.Longest {
+			meas.Outages.Longest = duration
+		}
+	}
+
+	meas.Workers = workers
+	return meas
+}
+
+/*
+Performance computes the throughput and the latency of the successful operations that
+started within the given windows. The entire measurement window is used if none is passed.
+*/
+func (meas *Measurements) Performance(windows ...Window) Performance {
+	if len(windows) == 0 {
+		windows = []Window{meas.Window}
+	}
+
+	var perf Performance
+	var duration time.Duration
+	latencies := []time.Duration{}
+	for _, window := range windows {
+		duration += window.End.Sub(window.Start)
+		for _, sample := range meas.samples {
+			if !sample.start.Before(window.Start) && sample.start.Before(window.End) {
+				latencies = append(latencies, sample.latency)
+			}
+		}
+	}
+
+	perf.SuccessfulOps = int64(len(latencies))
+	if duration > 0 {
+		perf.Throughput = float64(perf.SuccessfulOps) / duration.Seconds()
+	}
+
+	if len(latencies) == 0 {
+		return perf
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+
+	perf.Latency = Latency{
+		Average: total / time.Duration(len(latencies)),
+		Median:  latencies[len(latencies)/2],
+		P99:     latencies[(len(latencies)*99)/100],
+		Max:     latencies[len(latencies)-1],
+	}
+
+	return perf
 }
 
 func (meas *Measurements) String() string {
-	return strings.Join([]string{
+	perf := meas.Performance()
+	lines := append([]string{
 		fmt.Sprintf("Total Ops: %d", meas.TotalOps),
 		fmt.Sprintf("Lost Ops: %d", meas.LostOps),
 		fmt.Sprintf("Ghost Ops: %d", meas.GhostOps),
@@ -31,7 +193,31 @@ func (meas *Measurements) String() string {
 		fmt.Sprintf("\tCount: %d", meas.Outages.Count),
 		fmt.Sprintf("\tCumulative Duration: %s", meas.Outages.TotalDuration.String()),
 		fmt.Sprintf("\tLongest One: %s", meas.Outages.Longest.String()),
-	}, "\n")
+	}, perf.Lines()...)
+
+	if meas.SerializationFailures > 0 {
+		lines = append(lines, fmt.Sprintf("Serialization Failures: %d", meas.SerializationFailures))
+	}
+
+	if len(meas.LostWrites) > 0 {
+		lines = append(lines, "Lost Writes:")
+		for _, lostWrites := range meas.LostWrites {
+			lines = append(lines, fmt.Sprintf("\tCommitted Between: %s and %s", lostWrites.Start.Format("15:04:05.000"), lostWrites.End.Format("15:04:05.000")))
+		}
+	}
+
+	if len(meas.Violations) > 0 {
+		lines = append(lines, fmt.Sprintf("Invariant Violations: %d", len(meas.Violations)))
+		for _, violation := range meas.Violations {
+			lines = append(lines, fmt.Sprintf("\t%s: %s", violation.Time.Format("15:04:05.000"), violation.Description))
+		}
+	}
+
+	for idx, worker := range meas.Workers {
+		lines = append(lines, fmt.Sprintf("Worker %d:", idx+1), "\t"+strings.Join(strings.Split(worker.String(), "\n"), "\n\t"))
+	}
+
+	return strings.Join(lines, "\n")
 }
 
 type Anomaly int
@@ -54,7 +240,118 @@ type Tester interface {
 	Id() string
 }
 
+/*
+Reconciliation is the outcome of the verification of all the operations of a tester so far.
+*/
+type Reconciliation struct {
+	LostOps    int64
+	GhostOps   int64
+	LostWrites Window
+	Violations []string
+}
+
+/*
+Reconciler is implemented by testers that can verify all their previous operations, or the invariants they maintain, at once.
+The reconciliation happens when an outage ends and before the tester is cleaned up.
+*/
+type Reconciler interface {
+	Reconcile(*config.PgClientConfig) (Reconciliation, error)
+}
+
+/*
+violationTracker keeps the violations found by the last reconciliation of a tester,
+so that violations that persist across reconciliations are only reported once.
+*/
+type violationTracker map[string]bool
+
+func (tracker *violationTracker) newViolations(violations []string) []string {
+	current := violationTracker{}
+	reported := []string{}
+	for _, violation := range violations {
+		current[violation] = true
+		if !(*tracker)[violation] {
+			reported = append(reported, violation)
+		}
+	}
+	*tracker = current
+
+	return reported
+}
+
+func reconcile(tester Tester, pgConf *config.PgClientConfig, measurements *Measurements, log logger.Logger) {
+	reconciler, ok := tester.(Reconciler)
+	if !ok {
+		return
+	}
+
+	rec, recErr := reconciler.Reconcile(pgConf)
+	if recErr != nil {
+		log.Warnf("Tester \"%s\" could not reconcile its operations: %s", tester.Id(), recErr.Error())
+		return
+	}
+
+	if rec.LostOps > 0 {
+		measurements.LostOps += rec.LostOps
+		measurements.LostWrites = append(measurements.LostWrites, rec.LostWrites)
+		log.Infof("Tester \"%s\" lost %d committed transactions acknowledged between %s and %s", tester.Id(), rec.LostOps, rec.LostWrites.Start.Format("15:04:05.000"), rec.LostWrites.End.Format("15:04:05.000"))
+	}
+
+	if rec.GhostOps > 0 {
+		measurements.GhostOps += rec.GhostOps
+		log.Infof("Tester \"%s\" successfully committed %d transactions that were marked failures", tester.Id(), rec.GhostOps)
+	}
+
+	for _, violation := range rec.Violations {
+		measurements.Violations = append(measurements.Violations, Violation{Time: time.Now(), Description: violation})
+		log.Infof("Tester \"%s\" found a broken invariant: %s", tester.Id(), violation)
+	}
+}
+
+/*
+Probe gives access to the measurements of a running measure before it completes.
+*/
+type Probe struct {
+	lock         sync.Mutex
+	measurements Measurements
+	workers      []*Probe
+}
+
+func (probe *Probe) update(measurements Measurements) {
+	probe.lock.Lock()
+	defer probe.lock.Unlock()
+	probe.measurements = measurements
+}
+
+func (probe *Probe) setWorkers(workers []*Probe) {
+	probe.lock.Lock()
+	defer probe.lock.Unlock()
+	probe.workers = workers
+}
+
+func (probe *Probe) Snapshot() Measurements {
+	probe.lock.Lock()
+	defer probe.lock.Unlock()
+
+	if len(probe.workers) > 0 {
+		snapshots := []Measurements{}
+		for _, worker := range probe.workers {
+			snapshots = append(snapshots, worker.Snapshot())
+		}
+		return aggregate(snapshots)
+	}
+
+	snapshot := probe.measurements
+	snapshot.samples = append([]opSample{}, probe.measurements.samples...)
+	snapshot.outages = append([]Window{}, probe.measurements.outages...)
+	snapshot.Window.End = time.Now()
+	return snapshot
+}
+
 func Measure(tester Tester, pgConf *config.PgClientConfig, done <-chan struct{}, log logger.Logger) <-chan MeasureResult {
+	return MeasureWithProbe(tester, pgConf, done, &Probe{}, log)
+}
+
+func MeasureWithProbe(tester Tester, pgConf *config.PgClientConfig, done <-chan struct{}, probe *Probe, log logger.Logger) <-chan MeasureResult {
 	chRes := make(chan MeasureResult)
 
 	go func() {
@@ -65,11 +362,15 @@ func Measure(tester Tester, pgConf *config.PgClientConfig, done <-chan struct{},
 		}
 
 		var measurements Measurements
+		measurements.Window.Start = time.Now()
+		probe.update(measurements)
 
 		var outageSince *time.Time
 		for true {
 			select {
 			case <-done:
+				measurements.Window.End = time.Now()
+				reconcile(tester, pgConf, &measurements, log)
 				cleanupErr := tester.Cleanup(pgConf)
 				if cleanupErr != nil {
 					log.Warnf("Test cleanup failed for tester \"%s\"", tester.Id())
@@ -80,8 +381,27 @@ func Measure(tester Tester, pgConf *config.PgClientConfig, done <-chan struct{},
 			default:
 			}
 
+			opStart := time.Now()
 			anomaly, runErr := tester.Run(pgConf)
-			
+			opLatency := time.Since(opStart)
+
+			serializationFailed := false
+			var serializationFailures *SerializationFailures
+			if errors.As(runErr, &serializationFailures) {
+				measurements.SerializationFailures += serializationFailures.Count
+				serializationFailed = true
+				runErr = nil
+			}
+
+			var verificationFailure *VerificationFailure
+			if errors.As(runErr, &verificationFailure) {
+				for _, violation := range verificationFailure.Violations {
+					measurements.Violations = append(measurements.Violations, Violation{Time: time.Now(), Description: violation})
+					log.Infof("Tester \"%s\" found a broken invariant: %s", tester.Id(), violation)
+				}
+				runErr = nil
+			}
+
 			measurements.TotalOps += 1
 			switch anomaly {
 			case LostTransaction:
@@ -100,8 +420,12 @@ func Measure(tester Tester, pgConf *config.PgClientConfig, done <-chan struct{},
 					measurements.Outages.Count += 1
 				} 
 			} else {
+				if !serializationFailed {
+					measurements.samples = append(measurements.samples, opSample{start: opStart, latency: opLatency})
+				}
 				if outageSince != nil {
 					outageDuration := time.Since(*outageSince)
+					measurements.outages = append(measurements.outages, Window{Start: *outageSince, End: time.Now()})
 					outageSince = nil
 					if outageDuration.Nanoseconds() > measurements.Outages.Longest.Nanoseconds() {
 						measurements.Outages.Longest = outageDuration
@@ -109,10 +433,50 @@ func Measure(tester Tester, pgConf *config.PgClientConfig, done <-chan struct{},
 
 					measurements.Outages.TotalDuration = time.Duration(measurements.Outages.TotalDuration.Nanoseconds() + outageDuration.Nanoseconds())
 					log.Infof("Tester \"%s\" noticed a postgres outage for %s", tester.Id(), outageDuration.String())
+					reconcile(tester, pgConf, &measurements, log)
 				}
 			}
+
+			probe.update(measurements)
 		}
 	}()
 
 	return chRes
-}
\ No newline at end of file
+}
+
+/*
+MeasureWorkersWithProbe runs each tester in its own concurrent worker until the done channel is closed.
+The result holds the aggregated measurements of the workers along with the measurements of each worker.
+*/
+func MeasureWorkersWithProbe(testers []Tester, pgConf *config.PgClientConfig, done <-chan struct{}, probe *Probe, log logger.Logger) <-chan MeasureResult {
+	if len(testers) == 1 {
+		return MeasureWithProbe(testers[0], pgConf, done, probe, log)
+	}
+
+	workerProbes := []*Probe{}
+	workerResChs := []<-chan MeasureResult{}
+	for _, tester := range testers {
+		workerProbe := &Probe{}
+		workerProbes = append(workerProbes, workerProbe)
+		workerResChs = append(workerResChs, MeasureWithProbe(tester, pgConf, done, workerProbe, log))
+	}
+	probe.setWorkers(workerProbes)
+
+	chRes := make(chan MeasureResult)
+
+	go func() {
+		workers := []Measurements{}
+		var workersErr error
+		for idx, workerResCh := range workerResChs {
+			workerRes := <-workerResCh
+			workers = append(workers, workerRes.Measurements)
+			if workerRes.Error != nil && workersErr == nil {
+				workersErr = errors.New(fmt.Sprintf("Worker %d failed: %s", idx+1, workerRes.Error.Error()))
+			}
+		}
+
+		chRes <- MeasureResult{Measurements: aggregate(workers), Error: workersErr}
+	}()
+
+	return chRes
+}
@@ -0,0 +1,292 @@
+package measure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/config"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/random"
+)
+
+const serializableKeys = int64(5)
+const serializableConcurrency = 4
+
+/*
+SerializationFailures is returned by testers when some of their transactions were rolled back
+because they could not be serialized. Those are expected under the serializable isolation level,
+so they are counted separately rather than as outages.
+*/
+type SerializationFailures struct {
+	Count int64
+}
+
+func (failures *SerializationFailures) Error() string {
+	return fmt.Sprintf("%d transactions could not be serialized", failures.Count)
+}
+
+func isSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "40001"
+}
+
+/*
+Serializable runs concurrent serializable transactions that each read two keys, increment them
+and log the values they read. If the history is serializable, the values read for each key
+by the committed transactions are all distinct and the value of each key is the number of
+committed transactions that incremented it.
+*/
+type Serializable struct {
+	TableName  string
+	violations violationTracker
+	rnd        *rand.Rand
+}
+
+var workerSuffixRegex = regexp.MustCompile(`_[0-9]+$`)
+
+func (ser *Serializable) logTableName() string {
+	if len(ser.TableName) <= 59 {
+		return ser.TableName + "_log"
+	}
+
+	//The tables of workers end with the number of the worker, which keeps their log tables distinct
+	suffix := workerSuffixRegex.FindString(ser.TableName)
+	return ser.TableName[:59-len(suffix)] + suffix + "_log"
+}
+
+func (ser *Serializable) Initialize(conf *config.PgClientConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	defer cancel()
+	conn, connErr := pgx.Connect(ctx, conf.GetConnStr())
+	if connErr != nil {
+		return connErr
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		defer cancel()
+		conn.Close(ctx)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	tx, txErr := conn.Begin(ctx)
+	if txErr != nil {
+		return txErr
+	}
+
+	_, txErr = tx.Exec(ctx, fmt.Sprintf("CREATE TABLE %s (id bigint PRIMARY KEY, value bigint NOT NULL);", ser.TableName))
+	if txErr != nil {
+		return txErr
+	}
+
+	_, txErr = tx.Exec(ctx, fmt.Sprintf("CREATE TABLE %s (key bigint NOT NULL, read_value bigint NOT NULL);", ser.logTableName()))
+	if txErr != nil {
+		return txErr
+	}
+
+	_, txErr = tx.Exec(ctx, fmt.Sprintf("INSERT INTO %s (id, value) SELECT generate_series(1, $1), 0;", ser.TableName), serializableKeys)
+	if txErr != nil {
+		return txErr
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	return tx.Commit(ctx)
+}
+
+func (ser *Serializable) increment(conf *config.PgClientConfig, keys []int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	defer cancel()
+	conn, connErr := pgx.Connect(ctx, conf.GetConnStr())
+	if connErr != nil {
+		return connErr
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		defer cancel()
+		conn.Close(ctx)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	tx, txErr := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
+	if txErr != nil {
+		return txErr
+	}
+
+	for _, key := range keys {
+		var value int64
+		txErr = tx.QueryRow(ctx, fmt.Sprintf("SELECT value FROM %s WHERE id = $1;", ser.TableName), key).Scan(&value)
+		if txErr != nil {
+			tx.Rollback(ctx)
+			return txErr
+		}
+
+		_, txErr = tx.Exec(ctx, fmt.Sprintf("UPDATE %s SET value = $1 WHERE id = $2;", ser.TableName), value+1, key)
+		if txErr != nil {
+			tx.Rollback(ctx)
+			return txErr
+		}
+
+		_, txErr = tx.Exec(ctx, fmt.Sprintf("INSERT INTO %s (key, read_value) VALUES ($1, $2);", ser.logTableName()), key, value)
+		if txErr != nil {
+			tx.Rollback(ctx)
+			return txErr
+		}
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	return tx.Commit(ctx)
+}
+
+/*
+Run executes concurrent transactions on random pairs of keys. An error is returned if any transaction
+failed for another reason than a serialization failure. Otherwise, SerializationFailures is returned
+if some transactions could not be serialized.
+*/
+func (ser *Serializable) Run(conf *config.PgClientConfig) (Anomaly, error) {
+	var wg sync.WaitGroup
+	errs := make([]error, serializableConcurrency)
+
+	if ser.rnd == nil {
+		ser.rnd = random.New()
+	}
+
+	for idx := 0; idx < serializableConcurrency; idx++ {
+		first := ser.rnd.Int63n(serializableKeys) + 1
+		second := ser.rnd.Int63n(serializableKeys-1) + 1
+		if second >= first {
+			second += 1
+		}
+
+		//Keys are always updated in the same order to prevent deadlocks between transactions
+		if second < first {
+			first, second = second, first
+		}
+
+		wg.Add(1)
+		go func(idx int, keys []int64) {
+			defer wg.Done()
+			errs[idx] = ser.increment(conf, keys)
+		}(idx, []int64{first, second})
+	}
+	wg.Wait()
+
+	failures := int64(0)
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		if !isSerializationFailure(err) {
+			return NoProblem, err
+		}
+		failures += 1
+	}
+
+	if failures > 0 {
+		return NoProblem, &SerializationFailures{Count: failures}
+	}
+
+	return NoProblem, nil
+}
+
+/*
+Reconcile checks the history of the committed transactions for each key.
+Violations that persist from the previous reconciliation are not reported again.
+*/
+func (ser *Serializable) Reconcile(conf *config.PgClientConfig) (Reconciliation, error) {
+	var rec Reconciliation
+
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	defer cancel()
+	conn, connErr := pgx.Connect(ctx, conf.GetConnStr())
+	if connErr != nil {
+		return rec, connErr
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		defer cancel()
+		conn.Close(ctx)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	rows, queryErr := conn.Query(
+		ctx,
+		fmt.Sprintf(
+			"SELECT k.id, k.value, count(l.read_value), count(DISTINCT l.read_value) FROM %s k LEFT JOIN %s l ON l.key = k.id GROUP BY k.id, k.value ORDER BY k.id;",
+			ser.TableName,
+			ser.logTableName(),
+		),
+	)
+	if queryErr != nil {
+		return rec, queryErr
+	}
+	defer rows.Close()
+
+	violations := []string{}
+	keys := int64(0)
+	for rows.Next() {
+		var key, value, reads, distinctReads int64
+		scanErr := rows.Scan(&key, &value, &reads, &distinctReads)
+		if scanErr != nil {
+			return rec, scanErr
+		}
+		keys += 1
+
+		if distinctReads != reads {
+			violations = append(violations, fmt.Sprintf("Key %d was read with the same value by several committed transactions (%d reads of %d distinct values)", key, reads, distinctReads))
+		}
+
+		if value != reads {
+			violations = append(violations, fmt.Sprintf("Key %d has value %d while %d committed transactions incremented it", key, value, reads))
+		}
+	}
+
+	rowsErr := rows.Err()
+	if rowsErr != nil {
+		return rec, rowsErr
+	}
+
+	if keys != serializableKeys {
+		violations = append(violations, fmt.Sprintf("%d keys were found instead of %d", keys, serializableKeys))
+	}
+
+	rec.Violations = ser.violations.newViolations(violations)
+	return rec, nil
+}
+
+func (ser *Serializable) Cleanup(conf *config.PgClientConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	defer cancel()
+	conn, connErr := pgx.Connect(ctx, conf.GetConnStr())
+	if connErr != nil {
+		return connErr
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		defer cancel()
+		conn.Close(ctx)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	_, execErr := conn.Exec(ctx, fmt.Sprintf("DROP TABLE %s, %s;", ser.TableName, ser.logTableName()))
+	return execErr
+}
+
+func (ser *Serializable) Id() string {
+	return fmt.Sprintf("Serializable on %s", ser.TableName)
+}
@@ -0,0 +1,275 @@
+package measure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/command"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/config"
+)
+
+/*
+VerificationFailure is returned by testers whose operation succeeded, but whose verification
+of the state of the database failed. It is reported as a broken invariant rather than as an outage.
+Violations only holds the violations that were not already reported by the previous operation.
+*/
+type VerificationFailure struct {
+	Violations []string
+}
+
+func (failure *VerificationFailure) Error() string {
+	return fmt.Sprintf("verification failed: %s", strings.Join(failure.Violations, ", "))
+}
+
+/*
+SqlTemplateData is the data the sql of a Sql tester is rendered with.
+Index is the number of operations that were committed before the current one.
+*/
+type SqlTemplateData struct {
+	Table string
+	Index int64
+}
+
+/*
+Sql runs sql statements provided by the user. If a verification query is provided,
+it runs before each operation, in the same transaction, and is expected to return the
+index of the operation, from which lost and ghost transactions are detected.
+*/
+type Sql struct {
+	TableName     string
+	InitializeSql string
+	RunSql        string
+	VerifySql     string
+	CleanupSql    string
+	index         int64
+	violations    violationTracker
+}
+
+func readSqlFile(kind string, path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("Error reading the %s sql file at path '%s': %s", kind, path, err.Error()))
+	}
+
+	_, renderErr := command.Render(kind, string(content), SqlTemplateData{})
+	if renderErr != nil {
+		return "", errors.New(fmt.Sprintf("Error rendering the %s sql file at path '%s': %s", kind, path, renderErr.Error()))
+	}
+
+	return string(content), nil
+}
+
+/*
+NewSql creates a Sql tester with the sql read from the files in the configuration.
+Only the run sql is required.
+*/
+func NewSql(sqlConf *config.SqlTesterConfig, tableName string) (*Sql, error) {
+	if sqlConf.Run == "" {
+		return nil, errors.New("Sql testers need a run sql file")
+	}
+
+	initializeSql, err := readSqlFile("initialize", sqlConf.Initialize)
+	if err != nil {
+		return nil, err
+	}
+
+	runSql, err := readSqlFile("run", sqlConf.Run)
+	if err != nil {
+		return nil, err
+	}
+
+	verifySql, err := readSqlFile("verify", sqlConf.Verify)
+	if err != nil {
+		return nil, err
+	}
+
+	cleanupSql, err := readSqlFile("cleanup", sqlConf.Cleanup)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sql{
+		TableName:     tableName,
+		InitializeSql: initializeSql,
+		RunSql:        runSql,
+		VerifySql:     verifySql,
+		CleanupSql:    cleanupSql,
+	}, nil
+}
+
+/*
+checkTableUsage returns an error if the run or verify sql does not depend on the table of the tester,
+in which case concurrent workers would all operate on the same data.
+*/
+func (sq *Sql) checkTableUsage() error {
+	kinds := []string{"run", "verify"}
+	for idx, tmpl := range []string{sq.RunSql, sq.VerifySql} {
+		if tmpl == "" {
+			continue
+		}
+		kind := kinds[idx]
+
+		first, firstErr := command.Render(kind, tmpl, SqlTemplateData{Table: "first"})
+		if firstErr != nil {
+			return firstErr
+		}
+
+		second, secondErr := command.Render(kind, tmpl, SqlTemplateData{Table: "second"})
+		if secondErr != nil {
+			return secondErr
+		}
+
+		if first == second {
+			return errors.New(fmt.Sprintf("The %s sql of sql testers with several workers must use the {{.Table}} of the tester", kind))
+		}
+	}
+
+	return nil
+}
+
+func (sq *Sql) render(kind string, tmpl string) (string, error) {
+	return command.Render(kind, tmpl, SqlTemplateData{Table: sq.TableName, Index: sq.index})
+}
+
+/*
+execTx renders the given sql and executes it in its own transaction.
+*/
+func (sq *Sql) execTx(conf *config.PgClientConfig, kind string, tmpl string) error {
+	if tmpl == "" {
+		return nil
+	}
+
+	sql, renderErr := sq.render(kind, tmpl)
+	if renderErr != nil {
+		return renderErr
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	defer cancel()
+	conn, connErr := pgx.Connect(ctx, conf.GetConnStr())
+	if connErr != nil {
+		return connErr
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		defer cancel()
+		conn.Close(ctx)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	tx, txErr := conn.Begin(ctx)
+	if txErr != nil {
+		return txErr
+	}
+
+	_, txErr = tx.Exec(ctx, sql)
+	if txErr != nil {
+		return txErr
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	return tx.Commit(ctx)
+}
+
+func (sq *Sql) Initialize(conf *config.PgClientConfig) error {
+	return sq.execTx(conf, "initialize", sq.InitializeSql)
+}
+
+func (sq *Sql) Run(conf *config.PgClientConfig) (Anomaly, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	defer cancel()
+	conn, connErr := pgx.Connect(ctx, conf.GetConnStr())
+	if connErr != nil {
+		return NoProblem, connErr
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		defer cancel()
+		conn.Close(ctx)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	tx, txErr := conn.Begin(ctx)
+	if txErr != nil {
+		return NoProblem, txErr
+	}
+
+	anomaly := NoProblem
+	violations := []string{}
+	if sq.VerifySql != "" {
+		verifySql, renderErr := sq.render("verify", sq.VerifySql)
+		if renderErr != nil {
+			return anomaly, renderErr
+		}
+
+		//No value is expected before the first commit, ex: when the verified row is created by the run sql
+		var value *int64
+		queryErr := tx.QueryRow(ctx, verifySql).Scan(&value)
+		if errors.Is(queryErr, pgx.ErrNoRows) {
+			queryErr = nil
+		}
+		if queryErr != nil {
+			return anomaly, queryErr
+		}
+
+		if value == nil {
+			if sq.index > 0 {
+				violations = append(violations, "Verification query returned no value after operations were committed")
+			}
+		} else if *value < sq.index {
+			anomaly = LostTransaction
+			sq.index = *value
+		} else if *value > sq.index {
+			anomaly = GhostTransaction
+			sq.index = *value
+		}
+	}
+
+	runSql, renderErr := sq.render("run", sq.RunSql)
+	if renderErr != nil {
+		return anomaly, renderErr
+	}
+
+	_, txErr = tx.Exec(ctx, runSql)
+	if txErr != nil {
+		return anomaly, txErr
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
+	commErr := tx.Commit(ctx)
+	if commErr != nil {
+		return anomaly, commErr
+	}
+
+	sq.index += 1
+
+	newViolations := sq.violations.newViolations(violations)
+	if len(newViolations) > 0 {
+		return anomaly, &VerificationFailure{Violations: newViolations}
+	}
+
+	return anomaly, nil
+}
+
+func (sq *Sql) Cleanup(conf *config.PgClientConfig) error {
+	return sq.execTx(conf, "cleanup", sq.CleanupSql)
+}
+
+func (sq *Sql) Id() string {
+	return fmt.Sprintf("Sql on %s", sq.TableName)
+}
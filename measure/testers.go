@@ -0,0 +1,84 @@
+package measure
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/config"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/random"
+)
+
+/*
+NewTester creates the tester described by the configuration. The table name is used
+if the configuration does not specify one.
+*/
+func NewTester(testerConf *config.TesterConfig, tableName string) (Tester, error) {
+	if testerConf.Table != "" {
+		tableName = testerConf.Table
+	}
+
+	switch strings.ToLower(testerConf.Kind) {
+	case "", "updater":
+		return &Updater{TableName: tableName}, nil
+	case "inserter":
+		return &Inserter{TableName: tableName}, nil
+	case "bank":
+		return &Bank{TableName: tableName, rnd: random.New()}, nil
+	case "serializable":
+		return &Serializable{TableName: tableName, rnd: random.New()}, nil
+	case "sql":
+		sqlTester, sqlErr := NewSql(&testerConf.Sql, tableName)
+		if sqlErr != nil {
+			return nil, sqlErr
+		}
+		return sqlTester, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("Unsupported tester kind \"%s\"", testerConf.Kind))
+	}
+}
+
+/*
+NewTesters creates a tester for each of the workers described by the configuration.
+When there are several workers, each one gets its own table, suffixed with its number.
+*/
+func NewTesters(testerConf *config.TesterConfig, tableName string) ([]Tester, error) {
+	workers := testerConf.Workers
+	if workers <= 1 {
+		tester, testerErr := NewTester(testerConf, tableName)
+		if testerErr != nil {
+			return nil, testerErr
+		}
+		return []Tester{tester}, nil
+	}
+
+	if testerConf.Table != "" {
+		tableName = testerConf.Table
+	}
+
+	testers := []Tester{}
+	for idx := int64(0); idx < workers; idx++ {
+		suffix := fmt.Sprintf("_%d", idx+1)
+		workerTable := tableName
+		if len(workerTable)+len(suffix) > 63 {
+			workerTable = workerTable[:63-len(suffix)]
+		}
+
+		workerConf := *testerConf
+		workerConf.Table = workerTable + suffix
+		tester, testerErr := NewTester(&workerConf, workerConf.Table)
+		if testerErr != nil {
+			return nil, testerErr
+		}
+
+		if sqlTester, ok := tester.(*Sql); ok {
+			tableErr := sqlTester.checkTableUsage()
+			if tableErr != nil {
+				return nil, tableErr
+			}
+		}
+		testers = append(testers, tester)
+	}
+
+	return testers, nil
+}
@@ -15,24 +15,28 @@ type Updater struct {
 }
 
 func (up *Updater) Initialize(conf *config.PgClientConfig) error {
-	ctx, _ := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	defer cancel()
 	conn, connErr := pgx.Connect(ctx, conf.GetConnStr())
 	if connErr != nil {
 		return connErr
 	}
 
 	defer func() {
-		ctx, _ := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		defer cancel()
 		conn.Close(ctx)
 	}()
 
-	ctx, _ = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
 	tx, txErr := conn.Begin(ctx)
 	if txErr != nil {
 		return txErr
 	}
 
-	ctx, _ = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
 	_, txErr = tx.Exec(ctx, fmt.Sprintf("CREATE TABLE %s (value bigint NOT NULL);", up.TableName))
 	if txErr != nil {
 		return txErr
@@ -43,24 +47,28 @@ func (up *Updater) Initialize(conf *config.PgClientConfig) error {
 		return txErr
 	}
 
-	ctx, _ = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
 	commErr := tx.Commit(ctx)
 	return commErr
 }
 
 func (up *Updater) Run(conf *config.PgClientConfig) (Anomaly, error) {
-	ctx, _ := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	defer cancel()
 	conn, connErr := pgx.Connect(ctx, conf.GetConnStr())
 	if connErr != nil {
 		return NoProblem, connErr
 	}
 
 	defer func() {
-		ctx, _ := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		defer cancel()
 		conn.Close(ctx)
 	}()
 
-	ctx, _ = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
 	tx, txErr := conn.Begin(ctx)
 	if txErr != nil {
 		return NoProblem, txErr
@@ -69,7 +77,8 @@ func (up *Updater) Run(conf *config.PgClientConfig) (Anomaly, error) {
 	anomaly := NoProblem
 	if up.index > 0 {
 		queryErr := func() error {
-			ctx, _ = context.WithTimeout(context.Background(), conf.QueryTimeout)
+			ctx, cancel := context.WithTimeout(context.Background(), conf.QueryTimeout)
+			defer cancel()
 			rows, queryErr := tx.Query(ctx, fmt.Sprintf("SELECT value from %s;", up.TableName))
 			if queryErr != nil {
 				return queryErr
@@ -107,12 +116,15 @@ func (up *Updater) Run(conf *config.PgClientConfig) (Anomaly, error) {
 		}
 	}
 
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
 	_, txErr = tx.Exec(ctx, fmt.Sprintf("UPDATE %s SET value = $1;", up.TableName), up.index)
 	if txErr != nil {
 		return anomaly, txErr
 	}
 
-	ctx, _ = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
 	commErr := tx.Commit(ctx)
 	if commErr != nil {
 		return anomaly, commErr
@@ -124,34 +136,39 @@ func (up *Updater) Run(conf *config.PgClientConfig) (Anomaly, error) {
 }
 
 func (up *Updater) Cleanup(conf *config.PgClientConfig) error {
-	ctx, _ := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+	defer cancel()
 	conn, connErr := pgx.Connect(ctx, conf.GetConnStr())
 	if connErr != nil {
 		return connErr
 	}
 
 	defer func() {
-		ctx, _ := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		ctx, cancel := context.WithTimeout(context.Background(), conf.ConnectionTimeout)
+		defer cancel()
 		conn.Close(ctx)
 	}()
 
-	ctx, _ = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
 	tx, txErr := conn.Begin(ctx)
 	if txErr != nil {
 		return txErr
 	}
 
-	ctx, _ = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
 	_, txErr = tx.Exec(ctx, fmt.Sprintf("DROP TABLE %s;", up.TableName))
 	if txErr != nil {
 		return txErr
 	}
 
-	ctx, _ = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	ctx, cancel = context.WithTimeout(context.Background(), conf.QueryTimeout)
+	defer cancel()
 	commErr := tx.Commit(ctx)
 	return commErr
 }
 
 func (up *Updater) Id() string {
-	return "Updater"
+	return fmt.Sprintf("Updater on %s", up.TableName)
 }
\ No newline at end of file
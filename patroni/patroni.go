@@ -8,20 +8,33 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/config"
 	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/logger"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/random"
 )
 
+const MinSwitchoverScheduleDelay = config.MinSwitchoverScheduleDelay
+
+/*
+Callers compute the scheduled time of a switchover slightly before requesting it,
+so they are given this much leeway on the minimum delay.
+*/
+const switchoverScheduleLeeway = time.Second
+
 var switchoverResponseRegex *regexp.Regexp
+var failoverResponseRegex *regexp.Regexp
 
 func init() {
 	switchoverResponseRegex = regexp.MustCompile(`^Successfully switched over to "(?P<leader>.*)"$`)
+	failoverResponseRegex = regexp.MustCompile(`^Successfully failed over to "(?P<leader>.*)"$`)
 }
 
 type PatroniMemberLag int64
@@ -37,19 +50,28 @@ func (lag *PatroniMemberLag) UnmarshalJSON(data []byte) error {
 }
 
 type PatroniMember struct {
-	Name     string           `json:"name"`
-	Role     string           `json:"role"`
-	State    string           `json:"state"`
-	ApiUrl   string           `json:"api_url"`
-	Host     string           `json:"host"`
-	Port     int64            `json:"port"`
-	Timeline int64            `json:"timeline"`
-	Lag      PatroniMemberLag `json:"lag"`
+	Name           string           `json:"name"`
+	Role           string           `json:"role"`
+	State          string           `json:"state"`
+	ApiUrl         string           `json:"api_url"`
+	Host           string           `json:"host"`
+	Port           int64            `json:"port"`
+	Timeline       int64            `json:"timeline"`
+	Lag            PatroniMemberLag `json:"lag"`
+	PendingRestart bool             `json:"pending_restart"`
+}
+
+type PatroniScheduledSwitchover struct {
+	At   string `json:"at"`
+	From string `json:"from"`
+	To   string `json:"to"`
 }
 
 type PatroniCluster struct {
-	Members []PatroniMember `json:"members"`
-	Scope   string          `json:"scope"`
+	Members             []PatroniMember             `json:"members"`
+	Scope               string                      `json:"scope"`
+	ScheduledSwitchover *PatroniScheduledSwitchover `json:"scheduled_switchover"`
+	Pause               bool                        `json:"pause"`
 }
 
 func (cluster *PatroniCluster) GetLeader() PatroniMember {
@@ -72,6 +94,32 @@ func (cluster *PatroniCluster) GetSyncStandby() PatroniMember {
 	return PatroniMember{}
 }
 
+func (cluster *PatroniCluster) GetAsyncReplica() PatroniMember {
+	replicas := []PatroniMember{}
+	for _, member := range cluster.Members {
+		if member.Role == "replica" {
+			replicas = append(replicas, member)
+		}
+	}
+
+	if len(replicas) == 0 {
+		return PatroniMember{}
+	}
+
+	return replicas[random.Intn(len(replicas))]
+}
+
+func (cluster *PatroniCluster) GetReplicas() []PatroniMember {
+	replicas := []PatroniMember{}
+	for _, member := range cluster.Members {
+		if member.Role != "leader" {
+			replicas = append(replicas, member)
+		}
+	}
+
+	return replicas
+}
+
 func (cluster *PatroniCluster) GetLeaderCandidate() PatroniMember {
 	replicas := []PatroniMember{}
 	for _, member := range cluster.Members {
@@ -88,17 +136,68 @@ func (cluster *PatroniCluster) GetLeaderCandidate() PatroniMember {
 		return PatroniMember{}
 	}
 
-	return replicas[rand.Intn(len(replicas))]
+	return replicas[random.Intn(len(replicas))]
 }
 
-func (cluster *PatroniCluster) IsHealthy(expectedCount int) bool {
+func (cluster *PatroniCluster) IsHealthy(expectedCount int, criteria *config.HealthConfig) bool {
+	return cluster.HealthIssue(expectedCount, criteria) == ""
+}
+
+func matchesLocation(member PatroniMember, locations []string) bool {
+	for _, location := range locations {
+		if member.Name == location || member.Host == location {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+HealthIssue returns the reason why the cluster is not healthy, or an empty string if it is.
+Beyond all the members being up with a known lag, the cluster must meet the given health criteria.
+*/
+func (cluster *PatroniCluster) HealthIssue(expectedCount int, criteria *config.HealthConfig) string {
+	leader := cluster.GetLeader()
+
 	for _, member := range cluster.Members {
-		if (member.State != "running" && member.State != "streaming") || member.Lag < PatroniMemberLag(0) {
-			return false
+		if member.State != "running" && member.State != "streaming" {
+			return fmt.Sprintf("Member \"%s\" is in state %s", member.Name, member.State)
+		}
+
+		if member.Lag < PatroniMemberLag(0) {
+			return fmt.Sprintf("Lag of member \"%s\" is unknown", member.Name)
+		}
+
+		if criteria.MaxLag != nil && int64(member.Lag) > *criteria.MaxLag {
+			return fmt.Sprintf("Member \"%s\" lags by %d bytes", member.Name, member.Lag)
+		}
+
+		if criteria.NoPendingRestart && member.PendingRestart {
+			return fmt.Sprintf("Member \"%s\" has a pending restart", member.Name)
 		}
+
+		if criteria.SameTimeline && leader.Name != "" && member.Timeline != leader.Timeline {
+			return fmt.Sprintf("Member \"%s\" is on timeline %d while the leader is on timeline %d", member.Name, member.Timeline, leader.Timeline)
+		}
+	}
+
+	if len(cluster.Members) != expectedCount {
+		return fmt.Sprintf("Cluster has %d members instead of %d", len(cluster.Members), expectedCount)
+	}
+
+	if criteria.RequireSyncStandby && cluster.GetSyncStandby().Name == "" {
+		return "Cluster has no synchronous standby"
 	}
 
-	return len(cluster.Members) == expectedCount
+	if len(criteria.LeaderLocations) > 0 && !matchesLocation(leader, criteria.LeaderLocations) {
+		if leader.Name == "" {
+			return "Cluster has no leader"
+		}
+		return fmt.Sprintf("Leader \"%s\" is not in one of the expected locations", leader.Name)
+	}
+
+	return ""
 }
 
 func getTlsConfigs(patrConf *config.PatroniClientConfig) (*tls.Config, error) {
@@ -120,23 +219,51 @@ func getTlsConfigs(patrConf *config.PatroniClientConfig) (*tls.Config, error) {
 		(*tlsConf).RootCAs = roots
 	}
 
-	certData, err := tls.LoadX509KeyPair(patrConf.Auth.ClientCert, patrConf.Auth.ClientKey)
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("Failed to patroni client certificate key pair: %s", err.Error()))
+	//Client cert, which is optional
+	if patrConf.Auth.ClientCert != "" || patrConf.Auth.ClientKey != "" {
+		certData, err := tls.LoadX509KeyPair(patrConf.Auth.ClientCert, patrConf.Auth.ClientKey)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Failed to patroni client certificate key pair: %s", err.Error()))
+		}
+		(*tlsConf).Certificates = []tls.Certificate{certData}
 	}
-	(*tlsConf).Certificates = []tls.Certificate{certData}
 
 	return tlsConf, nil
 }
 
 type PatroniClient struct {
-	client   *http.Client
-	endpoint string
-	log      logger.Logger
-	conf     *config.PatroniClientConfig
+	client    *http.Client
+	scheme    string
+	endpoint  string
+	endpoints []string
+	log       logger.Logger
+	conf      *config.PatroniClientConfig
 }
 
 func NewPatroniClient(patrConf *config.PatroniClientConfig, log logger.Logger) (PatroniClient, error) {
+	endpoints := []string{}
+	if patrConf.Endpoint != "" {
+		endpoints = append(endpoints, patrConf.Endpoint)
+	}
+	for _, endpoint := range patrConf.Endpoints {
+		if endpoint != patrConf.Endpoint {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+
+	if len(endpoints) == 0 {
+		return PatroniClient{}, errors.New("Patroni client needs at least one endpoint")
+	}
+
+	scheme := strings.ToLower(patrConf.Scheme)
+	if scheme == "" {
+		scheme = "https"
+	}
+
+	if scheme != "https" && scheme != "http" {
+		return PatroniClient{}, errors.New(fmt.Sprintf("Unsupported patroni api scheme \"%s\"", patrConf.Scheme))
+	}
+
 	tlsConf, tlsConfErr := getTlsConfigs(patrConf)
 	if tlsConfErr != nil {
 		return PatroniClient{}, tlsConfErr
@@ -145,39 +272,157 @@ func NewPatroniClient(patrConf *config.PatroniClientConfig, log logger.Logger) (
 	return PatroniClient{
 		client: &http.Client{
 			Transport: &http.Transport{
+				DialContext: (&net.Dialer{Timeout: patrConf.ConnectionTimeout}).DialContext,
 				TLSClientConfig: tlsConf,
 				TLSHandshakeTimeout: patrConf.ConnectionTimeout,
 				IdleConnTimeout: patrConf.RequestTimeout,
 				ResponseHeaderTimeout: patrConf.RequestTimeout,
 			},
 		},
-		endpoint: patrConf.Endpoint,
+		scheme: scheme,
+		endpoint: endpoints[0],
+		endpoints: endpoints,
 		log: log,
 		conf: patrConf,
 	}, nil
 }
 
+/*
+discoverEndpoints adds the api endpoints of the cluster members to the endpoints
+the client can fall back to.
+*/
+func (pClient *PatroniClient) discoverEndpoints(cluster *PatroniCluster) {
+	for _, member := range cluster.Members {
+		endpoint, endpointErr := memberEndpoint(member)
+		if endpointErr != nil {
+			continue
+		}
+
+		known := false
+		for _, knownEndpoint := range pClient.endpoints {
+			if knownEndpoint == endpoint {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			pClient.log.Debugf("Discovered patroni api endpoint \"%s\"", endpoint)
+			pClient.endpoints = append(pClient.endpoints, endpoint)
+		}
+	}
+}
+
 func (pClient *PatroniClient) GetCluster() (PatroniCluster, error) {
 	var cluster PatroniCluster
 
-	res, resErr := pClient.client.Get(fmt.Sprintf("https://%s/cluster", pClient.endpoint))
+	status, body, resErr := pClient.requestJson(http.MethodGet, "cluster", nil)
 	if resErr != nil {
 		return cluster, resErr
 	}
 
-	body, bodyErr := ioutil.ReadAll(res.Body)
-	if bodyErr != nil {
-		return cluster, bodyErr
+	if status != http.StatusOK {
+		return cluster, errors.New(fmt.Sprintf("Retrieval of the cluster failed with status %d: %s", status, string(body)))
 	}
 
 	parseErr := json.Unmarshal(body, &cluster)
-	
+	if parseErr == nil && pClient.conf.DiscoverEndpoints {
+		pClient.discoverEndpoints(&cluster)
+	}
+
 	return cluster, parseErr
 }
 
+/*
+canFallBack indicates whether a failed request can be sent again to another endpoint.
+Read requests are sent again if they failed or if the endpoint answered with a server error.
+Requests that may change the cluster are only sent again if they could not be sent at all.
+*/
+func canFallBack(method string, status int, err error) bool {
+	if method == http.MethodGet {
+		return err != nil || status >= http.StatusInternalServerError
+	}
+
+	if err == nil {
+		return false
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+/*
+requestJson sends a request to the patroni api for operations that apply to the entire cluster.
+If the request cannot be completed on the current endpoint, the other known endpoints are tried in turn
+and the first one that answers becomes the current endpoint.
+*/
+func (pClient *PatroniClient) requestJson(method string, path string, reqBody interface{}) (int, []byte, error) {
+	status, body, err := pClient.requestJsonAt(pClient.endpoint, method, path, reqBody)
+	if !canFallBack(method, status, err) {
+		return status, body, err
+	}
+
+	for _, endpoint := range pClient.endpoints {
+		if endpoint == pClient.endpoint {
+			continue
+		}
+
+		fbStatus, fbBody, fbErr := pClient.requestJsonAt(endpoint, method, path, reqBody)
+		if canFallBack(method, fbStatus, fbErr) {
+			continue
+		}
+
+		if fbErr == nil {
+			pClient.log.Warnf("Patroni api at \"%s\" could not be used, switching to \"%s\"", pClient.endpoint, endpoint)
+			pClient.endpoint = endpoint
+		}
+
+		return fbStatus, fbBody, fbErr
+	}
+
+	return status, body, err
+}
+
+/*
+requestJsonAt sends a request to the patroni api of a specific member, for operations that apply to the member they are sent to.
+*/
+func (pClient *PatroniClient) requestJsonAt(endpoint string, method string, path string, reqBody interface{}) (int, []byte, error) {
+	body := []byte{}
+	if reqBody != nil {
+		var bodyErr error
+		body, bodyErr = json.Marshal(reqBody)
+		if bodyErr != nil {
+			return 0, nil, bodyErr
+		}
+	}
+
+	req, reqErr := http.NewRequest(method, fmt.Sprintf("%s://%s/%s", pClient.scheme, endpoint, path), bytes.NewBuffer(body))
+	if reqErr != nil {
+		return 0, nil, reqErr
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if pClient.conf.Auth.Username != "" {
+		req.SetBasicAuth(pClient.conf.Auth.Username, pClient.conf.Auth.Password)
+	}
+
+	res, resErr := pClient.client.Do(req)
+	if resErr != nil {
+		return 0, nil, resErr
+	}
+	defer res.Body.Close()
+
+	body, bodyErr := ioutil.ReadAll(res.Body)
+	if bodyErr != nil {
+		return res.StatusCode, nil, bodyErr
+	}
+
+	return res.StatusCode, body, nil
+}
+
 type switchoverReqBody struct {
-	Leader    string `json:"leader"`
-	Candidate string `json:"candidate,omitempty"`
+	Leader      string `json:"leader"`
+	Candidate   string `json:"candidate,omitempty"`
+	ScheduledAt string `json:"scheduled_at,omitempty"`
 }
 
 type SwitchoverResult struct {
@@ -185,9 +430,18 @@ type SwitchoverResult struct {
 	NewLeader     string
 }
 
-func (pClient *PatroniClient) Switchover(excludeLeader bool) (SwitchoverResult, error) {
+/*
+Switchover changes the leader right away if scheduledAt is the zero time.
+Otherwise, patroni is asked to perform the switchover at the scheduled time,
+which must be at least MinSwitchoverScheduleDelay ahead, and is expected to report it as scheduled.
+*/
+func (pClient *PatroniClient) Switchover(excludeLeader bool, scheduledAt time.Time) (SwitchoverResult, error) {
 	result := SwitchoverResult{}
-	
+
+	if !scheduledAt.IsZero() && time.Until(scheduledAt) < MinSwitchoverScheduleDelay-switchoverScheduleLeeway {
+		return result, errors.New(fmt.Sprintf("Switchovers must be scheduled at least %s ahead", MinSwitchoverScheduleDelay.String()))
+	}
+
 	cluster, clusterErr := pClient.GetCluster()
 	if clusterErr != nil {
 		return result, clusterErr
@@ -208,19 +462,31 @@ func (pClient *PatroniClient) Switchover(excludeLeader bool) (SwitchoverResult,
 		reqBody.Candidate = candidate.Name
 	}
 
-	body, bodyErr := json.Marshal(reqBody)
-	if bodyErr != nil {
-		return result, bodyErr
+	if !scheduledAt.IsZero() {
+		reqBody.ScheduledAt = scheduledAt.Format(time.RFC3339)
 	}
 
-	res, resErr := pClient.client.Post(fmt.Sprintf("https://%s/switchover", pClient.endpoint), "application/json", bytes.NewBuffer(body))
+	status, body, resErr := pClient.requestJson(http.MethodPost, "switchover", reqBody)
 	if resErr != nil {
 		return result, resErr
 	}
 
-	body, bodyErr = ioutil.ReadAll(res.Body)
-	if bodyErr != nil {
-		return result, bodyErr
+	if !scheduledAt.IsZero() {
+		if status != http.StatusAccepted {
+			return result, errors.New(fmt.Sprintf("Switchover scheduled at %s was refused by patroni with status %d: %s", reqBody.ScheduledAt, status, string(body)))
+		}
+
+		cluster, clusterErr = pClient.GetCluster()
+		if clusterErr != nil {
+			return result, clusterErr
+		}
+
+		if cluster.ScheduledSwitchover == nil {
+			return result, errors.New("Patroni does not report the switchover as scheduled")
+		}
+		pClient.log.Infof("Switchover from leader \"%s\" to \"%s\" is scheduled at %s", cluster.ScheduledSwitchover.From, cluster.ScheduledSwitchover.To, cluster.ScheduledSwitchover.At)
+
+		return result, nil
 	}
 
 	if switchoverResponseRegex.MatchString(string(body)) {
@@ -231,15 +497,384 @@ func (pClient *PatroniClient) Switchover(excludeLeader bool) (SwitchoverResult,
 	return result, nil
 }
 
+type failoverReqBody struct {
+	Candidate string `json:"candidate"`
+}
+
+/*
+Failover promotes the candidate without requiring a healthy leader, unlike Switchover.
+*/
+func (pClient *PatroniClient) Failover(candidate string) (SwitchoverResult, error) {
+	result := SwitchoverResult{}
+
+	cluster, clusterErr := pClient.GetCluster()
+	if clusterErr != nil {
+		return result, clusterErr
+	}
+
+	result.PreviousLeader = cluster.GetLeader().Name
+
+	status, body, resErr := pClient.requestJson(http.MethodPost, "failover", failoverReqBody{Candidate: candidate})
+	if resErr != nil {
+		return result, resErr
+	}
+
+	if status != http.StatusOK {
+		return result, errors.New(fmt.Sprintf("Failover to \"%s\" was refused by patroni with status %d: %s", candidate, status, string(body)))
+	}
+
+	if failoverResponseRegex.MatchString(string(body)) {
+		match := failoverResponseRegex.FindStringSubmatch(string(body))
+		result.NewLeader = string(match[1])
+	}
+
+	return result, nil
+}
+
+/*
+GetConfig returns the dynamic configuration of the cluster, as it is stored in the dcs.
+*/
+func (pClient *PatroniClient) GetConfig() (map[string]interface{}, error) {
+	dynConf := map[string]interface{}{}
+
+	status, body, resErr := pClient.requestJson(http.MethodGet, "config", nil)
+	if resErr != nil {
+		return dynConf, resErr
+	}
+
+	if status != http.StatusOK {
+		return dynConf, errors.New(fmt.Sprintf("Retrieval of the configuration failed with status %d: %s", status, string(body)))
+	}
+
+	err := json.Unmarshal(body, &dynConf)
+	return dynConf, err
+}
+
+/*
+PatchConfig merges the given changes in the dynamic configuration of the cluster and returns the resulting configuration.
+Keys with a nil value are removed from the configuration.
+*/
+func (pClient *PatroniClient) PatchConfig(changes map[string]interface{}) (map[string]interface{}, error) {
+	dynConf := map[string]interface{}{}
+
+	status, body, resErr := pClient.requestJson(http.MethodPatch, "config", changes)
+	if resErr != nil {
+		return dynConf, resErr
+	}
+
+	if status != http.StatusOK {
+		return dynConf, errors.New(fmt.Sprintf("Configuration change was refused by patroni with status %d: %s", status, string(body)))
+	}
+
+	err := json.Unmarshal(body, &dynConf)
+	return dynConf, err
+}
+
+func (pClient *PatroniClient) setPause(pause bool) error {
+	_, patchErr := pClient.PatchConfig(map[string]interface{}{"pause": pause})
+	if patchErr != nil {
+		return patchErr
+	}
+
+	cluster, clusterErr := pClient.GetCluster()
+	if clusterErr != nil {
+		return clusterErr
+	}
+
+	if cluster.Pause != pause {
+		return errors.New(fmt.Sprintf("Patroni reports the pause as %t after it was changed to %t", cluster.Pause, pause))
+	}
+
+	return nil
+}
+
+/*
+Pause puts the cluster in maintenance mode, where patroni stops managing postgres and does not perform automatic failovers.
+*/
+func (pClient *PatroniClient) Pause() error {
+	err := pClient.setPause(true)
+	if err != nil {
+		return err
+	}
+
+	pClient.log.Infof("Patroni cluster has been paused")
+	return nil
+}
+
+func (pClient *PatroniClient) Resume() error {
+	err := pClient.setPause(false)
+	if err != nil {
+		return err
+	}
+
+	pClient.log.Infof("Patroni cluster has been resumed")
+	return nil
+}
+
+type SyncMode struct {
+	Synchronous bool
+	Strict      bool
+}
+
+func (mode SyncMode) String() string {
+	if !mode.Synchronous {
+		return "asynchronous mode"
+	}
+
+	if mode.Strict {
+		return "strict synchronous mode"
+	}
+
+	return "synchronous mode"
+}
+
+func getConfigBool(dynConf map[string]interface{}, key string) bool {
+	switch val := dynConf[key].(type) {
+	case bool:
+		return val
+	case string:
+		return strings.ToLower(val) == "true" || strings.ToLower(val) == "on"
+	default:
+		return false
+	}
+}
+
+func getSyncMode(dynConf map[string]interface{}) SyncMode {
+	return SyncMode{
+		Synchronous: getConfigBool(dynConf, "synchronous_mode"),
+		Strict:      getConfigBool(dynConf, "synchronous_mode_strict"),
+	}
+}
+
+func (pClient *PatroniClient) GetSyncMode() (SyncMode, error) {
+	dynConf, confErr := pClient.GetConfig()
+	if confErr != nil {
+		return SyncMode{}, confErr
+	}
+
+	return getSyncMode(dynConf), nil
+}
+
+/*
+SetSyncMode changes the synchronous_mode and synchronous_mode_strict settings of the dynamic configuration of the cluster.
+*/
+func (pClient *PatroniClient) SetSyncMode(mode SyncMode) error {
+	dynConf, patchErr := pClient.PatchConfig(map[string]interface{}{
+		"synchronous_mode":        mode.Synchronous,
+		"synchronous_mode_strict": mode.Strict,
+	})
+	if patchErr != nil {
+		return patchErr
+	}
+
+	if getSyncMode(dynConf) != mode {
+		return errors.New(fmt.Sprintf("Patroni reports the cluster in %s after it was changed to %s", getSyncMode(dynConf).String(), mode.String()))
+	}
+
+	pClient.log.Infof("Patroni cluster has been set in %s", mode.String())
+	return nil
+}
+
+/*
+WaitForNoFailover watches the cluster for the given duration and returns an error if a
+member other than the given leader takes the leadership.
+*/
+func (pClient *PatroniClient) WaitForNoFailover(leader string, duration time.Duration) error {
+	deadline := time.NewTimer(duration)
+
+	for {
+		select {
+		case <-deadline.C:
+			return nil
+		default:
+		}
+
+		cluster, clusterErr := pClient.GetCluster()
+		if clusterErr == nil {
+			newLeader := cluster.GetLeader().Name
+			if newLeader != "" && newLeader != leader {
+				return errors.New(fmt.Sprintf("Leadership was taken over by \"%s\" from \"%s\"", newLeader, leader))
+			}
+		}
+
+		time.Sleep(time.Second)
+	}
+}
+
+func memberEndpoint(member PatroniMember) (string, error) {
+	apiUrl, parseErr := url.Parse(member.ApiUrl)
+	if parseErr != nil {
+		return "", errors.New(fmt.Sprintf("Failed to parse the api url of member \"%s\": %s", member.Name, parseErr.Error()))
+	}
+
+	return apiUrl.Host, nil
+}
+
+type RestartOptions struct {
+	RestartPending bool   `json:"restart_pending,omitempty"`
+	Role           string `json:"role,omitempty"`
+}
+
+/*
+Restart restarts postgres on the member through its patroni api. The restart is only performed
+if the member matches the filters of the options.
+*/
+func (pClient *PatroniClient) Restart(member PatroniMember, opts RestartOptions) error {
+	endpoint, endpointErr := memberEndpoint(member)
+	if endpointErr != nil {
+		return endpointErr
+	}
+
+	status, body, resErr := pClient.requestJsonAt(endpoint, http.MethodPost, "restart", opts)
+	if resErr != nil {
+		return resErr
+	}
+
+	if status != http.StatusOK {
+		return errors.New(fmt.Sprintf("Restart of postgres on member \"%s\" failed with status %d: %s", member.Name, status, string(body)))
+	}
+
+	pClient.log.Infof("Postgres of server \"%s\" has been restarted", member.Name)
+	return nil
+}
+
+type reinitializeReqBody struct {
+	Force bool `json:"force"`
+}
+
+type memberStatus struct {
+	PostmasterStartTime string `json:"postmaster_start_time"`
+}
+
+/*
+getPostmasterStartTime returns the start time of postgres, as reported by the patroni api of the member.
+*/
+func (pClient *PatroniClient) getPostmasterStartTime(member PatroniMember) (string, error) {
+	endpoint, endpointErr := memberEndpoint(member)
+	if endpointErr != nil {
+		return "", endpointErr
+	}
+
+	status, body, resErr := pClient.requestJsonAt(endpoint, http.MethodGet, "patroni", nil)
+	if resErr != nil {
+		return "", resErr
+	}
+
+	if status != http.StatusOK {
+		return "", errors.New(fmt.Sprintf("Status of member \"%s\" could not be retrieved, got status %d: %s", member.Name, status, string(body)))
+	}
+
+	var memStatus memberStatus
+	jsonErr := json.Unmarshal(body, &memStatus)
+	if jsonErr != nil {
+		return "", jsonErr
+	}
+
+	return memStatus.PostmasterStartTime, nil
+}
+
+type ReinitializeResult struct {
+	Member              PatroniMember
+	PostmasterStartTime string
+}
+
+/*
+Reinitialize wipes the data of a replica and rebuilds it from the leader through its patroni api.
+The start time of postgres before the reinitialization is returned so that its completion can be detected.
+*/
+func (pClient *PatroniClient) Reinitialize(member PatroniMember) (ReinitializeResult, error) {
+	result := ReinitializeResult{Member: member}
+
+	endpoint, endpointErr := memberEndpoint(member)
+	if endpointErr != nil {
+		return result, endpointErr
+	}
+
+	startTime, startTimeErr := pClient.getPostmasterStartTime(member)
+	if startTimeErr != nil {
+		return result, startTimeErr
+	}
+	result.PostmasterStartTime = startTime
+
+	status, body, resErr := pClient.requestJsonAt(endpoint, http.MethodPost, "reinitialize", reinitializeReqBody{Force: true})
+	if resErr != nil {
+		return result, resErr
+	}
+
+	if status != http.StatusOK {
+		return result, errors.New(fmt.Sprintf("Reinitialization of member \"%s\" failed with status %d: %s", member.Name, status, string(body)))
+	}
+
+	pClient.log.Infof("Reinitialization of server \"%s\" has started", member.Name)
+	return result, nil
+}
+
+func (cluster *PatroniCluster) GetMember(name string) PatroniMember {
+	for _, member := range cluster.Members {
+		if member.Name == name {
+			return member
+		}
+	}
+
+	return PatroniMember{}
+}
+
+/*
+WaitForStreaming waits for a reinitialized member to stream again without lag. The reinitialization
+is known to have happened once the member was seen not streaming or once postgres was started again on it,
+as a fast reinitialization may complete before the cluster state reflects it.
+*/
+func (pClient *PatroniClient) WaitForStreaming(reinit ReinitializeResult, timeout time.Duration) error {
+	name := reinit.Member.Name
+	deadline := time.NewTimer(timeout)
+
+	reinitialized := false
+	for {
+		select {
+		case <-deadline.C:
+			if !reinitialized {
+				return errors.New(fmt.Sprintf("Reinitialization of server \"%s\" was not observed within the deadline of %s", name, timeout.String()))
+			}
+			return errors.New(fmt.Sprintf("Server \"%s\" was not streaming without lag within the deadline of %s", name, timeout.String()))
+		default:
+		}
+
+		cluster, clusterErr := pClient.GetCluster()
+		if clusterErr == nil {
+			member := cluster.GetMember(name)
+			if member.State != "streaming" {
+				reinitialized = true
+			} else if member.Lag == PatroniMemberLag(0) {
+				if !reinitialized {
+					startTime, startTimeErr := pClient.getPostmasterStartTime(member)
+					reinitialized = startTimeErr == nil && startTime != reinit.PostmasterStartTime
+				}
+
+				if reinitialized {
+					return nil
+				}
+			}
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
 func (pClient *PatroniClient) WaitForHealthy(timeout time.Duration, expectedCount int) error {
 	deadline := time.NewTimer(timeout)
 
 	cluster, clusterErr := pClient.GetCluster()
 
-	for clusterErr != nil || !cluster.IsHealthy(expectedCount) {
+	for clusterErr != nil || !cluster.IsHealthy(expectedCount, &pClient.conf.Health) {
 		select {
 		case <-deadline.C:
-			return errors.New(fmt.Sprintf("Cluster was not healthy within the deadline of %s", timeout.String()))
+			var issue string
+			if clusterErr != nil {
+				issue = clusterErr.Error()
+			} else {
+				issue = cluster.HealthIssue(expectedCount, &pClient.conf.Health)
+			}
+			return errors.New(fmt.Sprintf("Cluster was not healthy within the deadline of %s: %s", timeout.String(), issue))
 		default:
 		}
 
@@ -247,6 +882,8 @@ func (pClient *PatroniClient) WaitForHealthy(timeout time.Duration, expectedCoun
 		if clusterErr != nil {
 			cli, cliErr := NewPatroniClient(pClient.conf, pClient.log)
 			if cliErr == nil {
+				cli.endpoint = pClient.endpoint
+				cli.endpoints = pClient.endpoints
 				(*pClient) = cli
 			}
 		}
@@ -263,7 +900,7 @@ func (pClient *PatroniClient) ForceLeaderChange(timeout time.Duration) error {
 		return clusterErr
 	}
 
-	switchRes, switchErr := pClient.Switchover(true)
+	switchRes, switchErr := pClient.Switchover(true, time.Time{})
 	if switchErr != nil {
 		return switchErr
 	}
@@ -285,4 +922,23 @@ func (pClient *PatroniClient) ForceLeaderChange(timeout time.Duration) error {
 	pClient.log.Infof("Switchover from leader \"%s\" to leader \"%s\" with healthy cluster in %s", switchRes.PreviousLeader, switchRes.NewLeader, time.Now().Sub(begining).String())
 
 	return nil
-}
\ No newline at end of file
+}
+
+func (pClient *PatroniClient) WaitForLeaderChange(previousLeader string, timeout time.Duration) error {
+	deadline := time.NewTimer(timeout)
+
+	cluster, clusterErr := pClient.GetCluster()
+
+	for clusterErr != nil || cluster.GetLeader().Name == "" || cluster.GetLeader().Name == previousLeader {
+		select {
+		case <-deadline.C:
+			return errors.New(fmt.Sprintf("Leader \"%s\" was not replaced within the deadline of %s", previousLeader, timeout.String()))
+		default:
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		cluster, clusterErr = pClient.GetCluster()
+	}
+
+	return nil
+}
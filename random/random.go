@@ -0,0 +1,43 @@
+package random
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+/*
+All the random choices of the tool are made from this source, so that seeding it
+once is enough to replay the same choices.
+*/
+var lock sync.Mutex
+var source = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+func Seed(seed int64) {
+	lock.Lock()
+	defer lock.Unlock()
+	source = rand.New(rand.NewSource(seed))
+}
+
+func Intn(n int) int {
+	lock.Lock()
+	defer lock.Unlock()
+	return source.Intn(n)
+}
+
+func Int63n(n int64) int64 {
+	lock.Lock()
+	defer lock.Unlock()
+	return source.Int63n(n)
+}
+
+/*
+New returns a source seeded from the shared source. Components that draw from their own
+goroutines take one when they are created, so that the order in which their draws interleave
+with the other random choices does not affect what is replayed from a seed.
+*/
+func New() *rand.Rand {
+	lock.Lock()
+	defer lock.Unlock()
+	return rand.New(rand.NewSource(source.Int63()))
+}
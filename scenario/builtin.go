@@ -0,0 +1,112 @@
+package scenario
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/config"
+)
+
+func leaderChangeSteps(change config.ScenarioStepConfig, timeout time.Duration) []config.ScenarioStepConfig {
+	return []config.ScenarioStepConfig{
+		change,
+		{Action: "wait_leader_change", Timeout: timeout},
+		{Action: "wait_healthy", Timeout: timeout},
+	}
+}
+
+func disruptionSteps(targets []string, disruption string, pause time.Duration, timeout time.Duration) []config.ScenarioStepConfig {
+	steps := []config.ScenarioStepConfig{
+		{Action: "disrupt", Targets: targets, Disruption: disruption},
+	}
+
+	if pause.Nanoseconds() > 0 {
+		steps = append(steps, config.ScenarioStepConfig{Action: "pause", Duration: pause})
+	}
+
+	return append(
+		steps,
+		config.ScenarioStepConfig{Action: "restore"},
+		config.ScenarioStepConfig{Action: "wait_healthy", Timeout: timeout},
+	)
+}
+
+func pausedDisruptionSteps(disruption string, observation time.Duration, timeout time.Duration) []config.ScenarioStepConfig {
+	return []config.ScenarioStepConfig{
+		{Action: "pause_cluster"},
+		{Action: "disrupt", Target: "leader", Disruption: disruption},
+		{Action: "wait_no_failover", Duration: observation},
+		{Action: "restore"},
+		{Action: "resume_cluster"},
+		{Action: "wait_healthy", Timeout: timeout},
+	}
+}
+
+/*
+BuiltinScenarios returns the scenarios of the predefined tests whose count is set in the
+tests configuration, in the order they are run. They all run the tester of the tests
+configuration, each on its own table named after the test and the kind of the tester.
+*/
+func BuiltinScenarios(tests *config.TestsConfig) []config.ScenarioConfig {
+	leader := []string{"leader"}
+	syncStandby := []string{"sync_standby"}
+	asyncReplica := []string{"async_replica"}
+
+	builtins := []struct {
+		name       string
+		table      string
+		iterations int64
+		steps      []config.ScenarioStepConfig
+	}{
+		{"switchovers", "switchover", tests.Switchovers, leaderChangeSteps(config.ScenarioStepConfig{Action: "switchover"}, tests.ChangeRecoverTimeout)},
+		{"scheduled switchovers", "scheduled_switchover", tests.ScheduledSwitchovers, leaderChangeSteps(config.ScenarioStepConfig{Action: "switchover", Delay: tests.SwitchoverScheduleDelay}, tests.ChangeRecoverTimeout)},
+		{"failovers", "failover", tests.Failovers, leaderChangeSteps(config.ScenarioStepConfig{Action: "failover"}, tests.ChangeRecoverTimeout)},
+		{"leader losses", "loss_leader", tests.LeaderLosses, disruptionSteps(leader, "destruction", tests.RebuildPause, tests.LossRecoverTimeout)},
+		{"sync standby losses", "loss_sync_standby", tests.SyncStanbyLosses, disruptionSteps(syncStandby, "destruction", tests.RebuildPause, tests.LossRecoverTimeout)},
+		{"leader reboots", "reboot_leader", tests.LeaderReboots, disruptionSteps(leader, "reboot", tests.RestartPause, tests.RebootRecoverTimeout)},
+		{"sync standby reboots", "reboot_sync_standby", tests.SyncStanbyReboots, disruptionSteps(syncStandby, "reboot", tests.RestartPause, tests.RebootRecoverTimeout)},
+		{"cluster reboots", "reboot_cluster", tests.ClusterReboots, disruptionSteps([]string{"cluster"}, "reboot", tests.RestartPause, tests.RebootRecoverTimeout)},
+		{"async replica losses", "loss_async_replica", tests.AsyncReplicaLosses, disruptionSteps(asyncReplica, "destruction", tests.RebuildPause, tests.LossRecoverTimeout)},
+		{"async replica reboots", "reboot_async_replica", tests.AsyncReplicaReboots, disruptionSteps(asyncReplica, "reboot", tests.RestartPause, tests.RebootRecoverTimeout)},
+		{"leader and sync standby losses", "loss_leader_and_sync_standby", tests.LeaderAndSyncStandbyLosses, disruptionSteps([]string{"leader", "sync_standby"}, "destruction", tests.RebuildPause, tests.LossRecoverTimeout)},
+		{"leader and sync standby reboots", "reboot_leader_and_sync_standby", tests.LeaderAndSyncStandbyReboots, disruptionSteps([]string{"leader", "sync_standby"}, "reboot", tests.RestartPause, tests.RebootRecoverTimeout)},
+		{"replicas losses", "loss_replicas", tests.ReplicasLosses, disruptionSteps([]string{"replicas"}, "destruction", tests.RebuildPause, tests.LossRecoverTimeout)},
+		{"replicas reboots", "reboot_replicas", tests.ReplicasReboots, disruptionSteps([]string{"replicas"}, "reboot", tests.RestartPause, tests.RebootRecoverTimeout)},
+		{"leader partitions", "partition_leader", tests.LeaderPartitions, disruptionSteps(leader, "partition", tests.PartitionDuration, tests.PartitionRecoverTimeout)},
+		{"leader degradations", "degradation_leader", tests.LeaderDegradations, disruptionSteps(leader, "degradation", tests.DegradationDuration, tests.DegradationRecoverTimeout)},
+		{"sync standby degradations", "degradation_sync_standby", tests.SyncStandbyDegradations, disruptionSteps(syncStandby, "degradation", tests.DegradationDuration, tests.DegradationRecoverTimeout)},
+		{"paused leader reboots", "paused_reboot_leader", tests.PausedLeaderReboots, pausedDisruptionSteps("reboot", tests.PauseObservation, tests.RebootRecoverTimeout)},
+		{"paused leader postgres kills", "paused_postgres_kill_leader", tests.PausedLeaderPostgresKills, pausedDisruptionSteps("postgres_kill", tests.PauseObservation, tests.KillRecoverTimeout)},
+		{"leader postgres restarts", "postgres_restart_leader", tests.LeaderPostgresRestarts, disruptionSteps(leader, "postgres_restart", 0, tests.RestartRecoverTimeout)},
+		{"sync standby postgres restarts", "postgres_restart_sync_standby", tests.SyncStandbyPostgresRestarts, disruptionSteps(syncStandby, "postgres_restart", 0, tests.RestartRecoverTimeout)},
+		{"sync standby reinitializations", "reinit_sync_standby", tests.SyncStandbyReinits, disruptionSteps(syncStandby, "reinitialization", 0, tests.ReinitRecoverTimeout)},
+		{"async replica reinitializations", "reinit_async_replica", tests.AsyncReplicaReinits, disruptionSteps(asyncReplica, "reinitialization", 0, tests.ReinitRecoverTimeout)},
+		{"leader postgres kills", "postgres_kill_leader", tests.LeaderPostgresKills, disruptionSteps(leader, "postgres_kill", tests.KillPause, tests.KillRecoverTimeout)},
+		{"sync standby postgres kills", "postgres_kill_sync_standby", tests.SyncStandbyPostgresKills, disruptionSteps(syncStandby, "postgres_kill", tests.KillPause, tests.KillRecoverTimeout)},
+		{"leader patroni kills", "patroni_kill_leader", tests.LeaderPatroniKills, disruptionSteps(leader, "patroni_kill", tests.KillPause, tests.KillRecoverTimeout)},
+		{"sync standby patroni kills", "patroni_kill_sync_standby", tests.SyncStandbyPatroniKills, disruptionSteps(syncStandby, "patroni_kill", tests.KillPause, tests.KillRecoverTimeout)},
+	}
+
+	kind := strings.ToLower(tests.Tester.Kind)
+	if kind == "" {
+		kind = "updater"
+	}
+
+	scens := []config.ScenarioConfig{}
+	for _, builtin := range builtins {
+		if builtin.iterations <= 0 {
+			continue
+		}
+
+		tester := tests.Tester
+		tester.Table = builtin.table + "_" + kind
+		scens = append(scens, config.ScenarioConfig{
+			Name:       builtin.name,
+			Tester:     tester,
+			Iterations: builtin.iterations,
+			Steps:      builtin.steps,
+		})
+	}
+
+	return scens
+}
@@ -0,0 +1,187 @@
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/config"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/measure"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/patroni"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/random"
+)
+
+var defaultChaosDisruptions = []string{"switchover", "destruction", "reboot"}
+var defaultChaosTargets = []string{"leader", "sync_standby"}
+
+func randomDuration(min time.Duration, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+
+	return min + time.Duration(random.Int63n(int64(max-min)))
+}
+
+func randomChoice(choices []string) string {
+	return choices[random.Intn(len(choices))]
+}
+
+func (runner *Runner) recoverTimeout(disruption string) time.Duration {
+	switch disruption {
+	case "switchover", "failover":
+		return runner.Conf.Tests.ChangeRecoverTimeout
+	case "destruction":
+		return runner.Conf.Tests.LossRecoverTimeout
+	case "reboot":
+		return runner.Conf.Tests.RebootRecoverTimeout
+	case "partition":
+		return runner.Conf.Tests.PartitionRecoverTimeout
+	case "degradation":
+		return runner.Conf.Tests.DegradationRecoverTimeout
+	case "postgres_restart":
+		return runner.Conf.Tests.RestartRecoverTimeout
+	case "reinitialization":
+		return runner.Conf.Tests.ReinitRecoverTimeout
+	default:
+		return runner.Conf.Tests.KillRecoverTimeout
+	}
+}
+
+/*
+compatibleTargets returns the targets that the disruption can be applied to.
+*/
+func compatibleTargets(disruption string, targets []string) []string {
+	compatible := []string{}
+	for _, target := range targets {
+		target = strings.ToLower(target)
+		if target == "cluster" && disruption != "destruction" && disruption != "reboot" {
+			continue
+		}
+		if target == "leader" && disruption == "reinitialization" {
+			continue
+		}
+		compatible = append(compatible, target)
+	}
+
+	return compatible
+}
+
+func chaosChoices(chaosConf *config.ChaosConfig) ([]string, []string) {
+	disruptions := chaosConf.Disruptions
+	if len(disruptions) == 0 {
+		disruptions = defaultChaosDisruptions
+	}
+
+	targets := chaosConf.Targets
+	if len(targets) == 0 {
+		targets = defaultChaosTargets
+	}
+
+	return disruptions, targets
+}
+
+/*
+validateChaos checks that each disruption to pick from can be applied to at least one of the targets.
+*/
+func validateChaos(chaosConf *config.ChaosConfig) error {
+	disruptions, targets := chaosChoices(chaosConf)
+	for _, disruption := range disruptions {
+		disruption = strings.ToLower(disruption)
+		if disruption == "switchover" || disruption == "failover" {
+			continue
+		}
+
+		if len(compatibleTargets(disruption, targets)) == 0 {
+			return errors.New(fmt.Sprintf("Chaos disruption \"%s\" cannot be applied to any of the targets \"%s\"", disruption, strings.Join(targets, "\", \"")))
+		}
+	}
+
+	return nil
+}
+
+/*
+chaosRound randomly generates the steps of a single disruption, followed by the recovery
+of the cluster and a rest period.
+*/
+func (runner *Runner) chaosRound(chaosConf *config.ChaosConfig) []config.ScenarioStepConfig {
+	disruptions, targets := chaosChoices(chaosConf)
+
+	disruption := strings.ToLower(randomChoice(disruptions))
+	steps := []config.ScenarioStepConfig{}
+	if disruption == "switchover" || disruption == "failover" {
+		steps = append(steps, config.ScenarioStepConfig{Action: disruption})
+	} else {
+		steps = append(
+			steps,
+			config.ScenarioStepConfig{Action: "disrupt", Target: randomChoice(compatibleTargets(disruption, targets)), Disruption: disruption},
+			config.ScenarioStepConfig{Action: "pause", Duration: randomDuration(chaosConf.MinPause, chaosConf.MaxPause)},
+			config.ScenarioStepConfig{Action: "restore"},
+		)
+	}
+
+	return append(
+		steps,
+		config.ScenarioStepConfig{Action: "wait_healthy", Timeout: runner.recoverTimeout(disruption)},
+		config.ScenarioStepConfig{Action: "sleep", Duration: randomDuration(chaosConf.MinInterval, chaosConf.MaxInterval)},
+	)
+}
+
+func describeRound(steps []config.ScenarioStepConfig) string {
+	if steps[0].Action != "disrupt" {
+		return steps[0].Action
+	}
+
+	return fmt.Sprintf("%s of %s for %s", steps[0].Disruption, steps[0].Target, steps[1].Duration.String())
+}
+
+/*
+RunChaos disrupts the cluster with randomly chosen disruptions, targets and pauses
+until the configured duration has elapsed.
+*/
+func (runner *Runner) RunChaos(chaosConf *config.ChaosConfig) (Report, error) {
+	var report Report
+
+	validErr := validateChaos(chaosConf)
+	if validErr != nil {
+		return report, validErr
+	}
+
+	testers, testerErr := measure.NewTesters(&chaosConf.Tester, "chaos_updater")
+	if testerErr != nil {
+		return report, testerErr
+	}
+
+	syncModes := &syncModeTracker{}
+	meas, tl, err := runner.measure(testers, func(pClient *patroni.PatroniClient, probe *measure.Probe) error {
+		deadline := time.Now().Add(chaosConf.Duration)
+		rounds := int64(0)
+		for time.Now().Before(deadline) {
+			steps := runner.chaosRound(chaosConf)
+			runner.Log.Infof("Chaos round %d: %s", rounds+1, describeRound(steps))
+
+			iter := iteration{
+				runner:    runner,
+				pClient:   pClient,
+				probe:     probe,
+				syncModes: syncModes,
+			}
+
+			iterErr := iter.run(steps)
+			report.DegradedWindows = append(report.DegradedWindows, iter.degradedWindows...)
+			if iterErr != nil {
+				return errors.New(fmt.Sprintf("Chaos round %d failed: %s", rounds+1, iterErr.Error()))
+			}
+
+			rounds += 1
+		}
+
+		runner.Log.Infof("Completed %d chaos rounds in %s", rounds, chaosConf.Duration.String())
+		return nil
+	})
+	report.Measurements = meas
+	report.Timeline = tl
+	report.SyncModePeriods = syncModes.close(meas)
+
+	return report, err
+}
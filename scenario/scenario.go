@@ -0,0 +1,177 @@
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/config"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/disruption"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/hooks"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/logger"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/measure"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/patroni"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/timeline"
+)
+
+var tableNameRegex *regexp.Regexp
+
+func init() {
+	tableNameRegex = regexp.MustCompile(`[^a-z0-9_]+`)
+}
+
+func tableName(scenarioName string) string {
+	name := "scenario_" + strings.Trim(tableNameRegex.ReplaceAllString(strings.ToLower(scenarioName), "_"), "_")
+	if len(name) > 63 {
+		return name[:63]
+	}
+	return name
+}
+
+type Report struct {
+	Measurements     measure.Measurements
+	DegradedWindows  []measure.Window
+	ScheduledWindows []measure.Window
+	SyncModePeriods  []SyncModePeriod
+	Timeline         timeline.Timeline
+}
+
+func (rep *Report) String() string {
+	lines := []string{rep.Measurements.String()}
+
+	if len(rep.DegradedWindows) > 0 {
+		degradedPerf := rep.Measurements.Performance(rep.DegradedWindows...)
+		lines = append(lines, "While the network was degraded:", "\t"+strings.Join(degradedPerf.Lines(), "\n\t"))
+	}
+
+	if len(rep.ScheduledWindows) > 0 {
+		scheduledPerf := rep.Measurements.Performance(rep.ScheduledWindows...)
+		lines = append(lines, "From the scheduled switchovers to the recovery of the cluster:", "\t"+strings.Join(scheduledPerf.Lines(), "\n\t"))
+	}
+
+	if len(rep.SyncModePeriods) > 0 {
+		lines = append(lines, syncModeLines(&rep.Measurements, rep.SyncModePeriods)...)
+	}
+
+	if rep.Timeline.Recorded() {
+		lines = append(lines, rep.Timeline.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+/*
+Runner runs the scenarios defined in the configuration, with the disruption
+backend and the hooks they may need to disrupt patroni members.
+*/
+type Runner struct {
+	Conf      *config.Config
+	Disruptor disruption.Disruptor
+	Hooks     *hooks.Hooks
+	Log       logger.Logger
+}
+
+/*
+measure runs the testers while the drive function disrupts the cluster and returns
+once both are done.
+*/
+func (runner *Runner) measure(testers []measure.Tester, drive func(pClient *patroni.PatroniClient, probe *measure.Probe) error) (measure.Measurements, timeline.Timeline, error) {
+	doneCh := make(chan struct{})
+	probe := &measure.Probe{}
+	measResCh := measure.MeasureWorkersWithProbe(
+		testers,
+		&runner.Conf.PgClient,
+		doneCh,
+		probe,
+		runner.Log,
+	)
+	tlCh := timeline.Record(&runner.Conf.PatroniClient, &runner.Conf.Timeline, doneCh, runner.Log)
+
+	driveResCh := make(chan error)
+
+	go func() {
+		defer func() {
+			select {
+			case <-doneCh:
+			default:
+				close(doneCh)
+			}
+		}()
+
+		pClient, pClientErr := patroni.NewPatroniClient(&runner.Conf.PatroniClient, runner.Log)
+		if pClientErr != nil {
+			driveResCh <- pClientErr
+			return
+		}
+
+		driveResCh <- drive(&pClient, probe)
+	}()
+
+	driveErr := <-driveResCh
+	measRes := <-measResCh
+	tl := <-tlCh
+
+	if driveErr != nil {
+		return measRes.Measurements, tl, driveErr
+	}
+
+	if measRes.Error != nil {
+		return measRes.Measurements, tl, errors.New(fmt.Sprintf("Error occurred while running transactions on postgres cluster: %s", measRes.Error.Error()))
+	}
+
+	return measRes.Measurements, tl, nil
+}
+
+func (runner *Runner) Run(scen *config.ScenarioConfig) (Report, error) {
+	var report Report
+
+	if scen.Name == "" {
+		return report, errors.New("Scenarios need a name")
+	}
+
+	testers, testerErr := measure.NewTesters(&scen.Tester, tableName(scen.Name))
+	if testerErr != nil {
+		return report, testerErr
+	}
+
+	iterCount := scen.Iterations
+	if iterCount == 0 {
+		iterCount = 1
+	}
+
+	syncModes := &syncModeTracker{}
+	meas, tl, err := runner.measure(testers, func(pClient *patroni.PatroniClient, probe *measure.Probe) error {
+		iterations := int64(0)
+		for iterations < iterCount {
+			iter := iteration{
+				runner:    runner,
+				pClient:   pClient,
+				probe:     probe,
+				syncModes: syncModes,
+			}
+
+			beginning := time.Now()
+
+			iterErr := iter.run(scen.Steps)
+			report.DegradedWindows = append(report.DegradedWindows, iter.degradedWindows...)
+			report.ScheduledWindows = append(report.ScheduledWindows, iter.scheduledWindows...)
+			if iterErr != nil {
+				return iterErr
+			}
+
+			runner.Log.Infof("Completed iteration %d of scenario \"%s\" in %s", iterations+1, scen.Name, time.Now().Sub(beginning).String())
+
+			time.Sleep(runner.Conf.Tests.ValidationInterval)
+			iterations += 1
+		}
+
+		return nil
+	})
+	report.Measurements = meas
+	report.Timeline = tl
+	report.SyncModePeriods = syncModes.close(meas)
+
+	return report, err
+}
@@ -0,0 +1,430 @@
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/config"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/hooks"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/measure"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/patroni"
+)
+
+type disruptedMembers struct {
+	members       []patroni.PatroniMember
+	cluster       patroni.PatroniCluster
+	disruption    string
+	degradedSince time.Time
+	reinits       []patroni.ReinitializeResult
+}
+
+type iteration struct {
+	runner           *Runner
+	pClient          *patroni.PatroniClient
+	probe            *measure.Probe
+	initialCluster   patroni.PatroniCluster
+	disrupted        []disruptedMembers
+	degradedWindows  []measure.Window
+	scheduledAt      time.Time
+	scheduledWindows []measure.Window
+	syncModes        *syncModeTracker
+	originalSyncMode *patroni.SyncMode
+	paused           bool
+}
+
+func memberNames(members []patroni.PatroniMember) string {
+	names := []string{}
+	for _, member := range members {
+		names = append(names, member.Name)
+	}
+	return strings.Join(names, "\", \"")
+}
+
+/*
+cleanup restores the servers that were left disrupted, resumes the cluster if it was left paused
+and restores the original synchronous mode of the cluster if it was changed.
+*/
+func (iter *iteration) cleanup() error {
+	if len(iter.disrupted) > 0 {
+		iter.runner.Log.Warnf("Restoring servers that were left disrupted at the end of the scenario")
+		resErr := iter.restore()
+		if resErr != nil {
+			return resErr
+		}
+	}
+
+	if iter.paused {
+		iter.runner.Log.Warnf("Resuming the cluster that was left paused at the end of the scenario")
+		resErr := iter.pClient.Resume()
+		if resErr != nil {
+			return resErr
+		}
+		iter.paused = false
+	}
+
+	if iter.originalSyncMode != nil {
+		iter.runner.Log.Infof("Restoring the original %s of the cluster", iter.originalSyncMode.String())
+		modeErr := iter.setSyncMode(*iter.originalSyncMode)
+		if modeErr != nil {
+			return modeErr
+		}
+		iter.originalSyncMode = nil
+	}
+
+	return nil
+}
+
+func (iter *iteration) run(steps []config.ScenarioStepConfig) (err error) {
+	clus, clusErr := iter.pClient.GetCluster()
+	if clusErr != nil {
+		return clusErr
+	}
+	iter.initialCluster = clus
+
+	defer func() {
+		cleanErr := iter.cleanup()
+		if cleanErr == nil {
+			return
+		}
+
+		if err != nil {
+			iter.runner.Log.Errorf("Error cleaning up after the failed scenario: %s", cleanErr.Error())
+			return
+		}
+		err = cleanErr
+	}()
+
+	for idx, step := range steps {
+		stepErr := iter.runStep(&step)
+		if stepErr != nil {
+			return errors.New(fmt.Sprintf("Step %d (%s) failed: %s", idx+1, step.Action, stepErr.Error()))
+		}
+	}
+
+	return nil
+}
+
+func (iter *iteration) runStep(step *config.ScenarioStepConfig) error {
+	switch strings.ToLower(step.Action) {
+	case "disrupt":
+		return iter.disrupt(step)
+	case "restore":
+		return iter.restore()
+	case "pause":
+		if len(iter.disrupted) > 0 {
+			iter.runner.Log.Infof("Pausing for %s with disrupted servers", step.Duration.String())
+		} else {
+			iter.runner.Log.Infof("Pausing for %s", step.Duration.String())
+		}
+		time.Sleep(step.Duration)
+		return nil
+	case "sleep":
+		time.Sleep(step.Duration)
+		return nil
+	case "wait_healthy":
+		beginning := time.Now()
+		healthErr := iter.pClient.WaitForHealthy(step.Timeout, len(iter.initialCluster.Members))
+		if healthErr != nil {
+			return healthErr
+		}
+		iter.runner.Log.Infof("Cluster was healthy after %s", time.Now().Sub(beginning).String())
+		if !iter.scheduledAt.IsZero() {
+			iter.scheduledWindows = append(iter.scheduledWindows, measure.Window{Start: iter.scheduledAt, End: time.Now()})
+			iter.scheduledAt = time.Time{}
+		}
+		return nil
+	case "wait_leader_change":
+		beginning := time.Now()
+		initialLeader := iter.initialCluster.GetLeader().Name
+		changeErr := iter.pClient.WaitForLeaderChange(initialLeader, step.Timeout)
+		if changeErr != nil {
+			return changeErr
+		}
+		iter.runner.Log.Infof("Leader \"%s\" was replaced after %s", initialLeader, time.Now().Sub(beginning).String())
+		return nil
+	case "wait_no_failover":
+		initialLeader := iter.initialCluster.GetLeader().Name
+		iter.runner.Log.Infof("Checking for %s that no failover happens away from leader \"%s\"", step.Duration.String(), initialLeader)
+		return iter.pClient.WaitForNoFailover(initialLeader, step.Duration)
+	case "switchover":
+		scheduledAt := time.Time{}
+		if step.Delay.Nanoseconds() > 0 {
+			scheduledAt = time.Now().Add(step.Delay)
+		}
+		switchRes, switchErr := iter.pClient.Switchover(true, scheduledAt)
+		if switchErr != nil {
+			return switchErr
+		}
+		if !scheduledAt.IsZero() {
+			iter.runner.Log.Infof("Switchover away from leader \"%s\" scheduled at %s, waiting for the scheduled time", switchRes.PreviousLeader, scheduledAt.Format(time.RFC3339))
+			time.Sleep(time.Until(scheduledAt))
+			iter.scheduledAt = scheduledAt
+			return nil
+		}
+		iter.runner.Log.Infof("Switchover requested away from leader \"%s\"", switchRes.PreviousLeader)
+		return nil
+	case "failover":
+		clus, clusErr := iter.pClient.GetCluster()
+		if clusErr != nil {
+			return clusErr
+		}
+		candidate := clus.GetLeaderCandidate()
+		if candidate.Name == "" {
+			return errors.New("No suitable failover candidate was found")
+		}
+		failRes, failErr := iter.pClient.Failover(candidate.Name)
+		if failErr != nil {
+			return failErr
+		}
+		iter.runner.Log.Infof("Failover requested from leader \"%s\" to \"%s\"", failRes.PreviousLeader, candidate.Name)
+		return nil
+	case "set_sync_mode":
+		mode, modeErr := iter.pClient.GetSyncMode()
+		if modeErr != nil {
+			return modeErr
+		}
+		if iter.originalSyncMode == nil {
+			original := mode
+			iter.originalSyncMode = &original
+		}
+		if step.SynchronousMode != nil {
+			mode.Synchronous = *step.SynchronousMode
+		}
+		if step.SynchronousModeStrict != nil {
+			mode.Strict = *step.SynchronousModeStrict
+		}
+		return iter.setSyncMode(mode)
+	case "pause_cluster":
+		pauseErr := iter.pClient.Pause()
+		if pauseErr != nil {
+			return pauseErr
+		}
+		iter.paused = true
+		return nil
+	case "resume_cluster":
+		resErr := iter.pClient.Resume()
+		if resErr != nil {
+			return resErr
+		}
+		iter.paused = false
+		return nil
+	case "assert":
+		return iter.assert(&step.Assert)
+	default:
+		return errors.New(fmt.Sprintf("Unsupported scenario step action \"%s\"", step.Action))
+	}
+}
+
+func getTarget(target string, clus *patroni.PatroniCluster) ([]patroni.PatroniMember, error) {
+	var member patroni.PatroniMember
+	switch strings.ToLower(target) {
+	case "leader":
+		member = clus.GetLeader()
+	case "sync_standby":
+		member = clus.GetSyncStandby()
+	case "async_replica":
+		member = clus.GetAsyncReplica()
+	case "cluster", "replicas":
+		members := clus.Members
+		if strings.ToLower(target) == "replicas" {
+			members = clus.GetReplicas()
+		}
+		if len(members) == 0 {
+			return nil, errors.New(fmt.Sprintf("No member of the cluster currently fills the %s target", target))
+		}
+		return members, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("Unsupported disruption target \"%s\"", target))
+	}
+
+	if member.Name == "" {
+		return nil, errors.New(fmt.Sprintf("No member of the cluster currently fills the %s target", target))
+	}
+
+	return []patroni.PatroniMember{member}, nil
+}
+
+func (iter *iteration) disrupt(step *config.ScenarioStepConfig) error {
+	clus, clusErr := iter.pClient.GetCluster()
+	if clusErr != nil {
+		return clusErr
+	}
+
+	targets := step.Targets
+	if len(targets) == 0 {
+		targets = []string{step.Target}
+	}
+
+	members := []patroni.PatroniMember{}
+	targeted := map[string]bool{}
+	for _, target := range targets {
+		targetMembers, targetErr := getTarget(target, &clus)
+		if targetErr != nil {
+			return targetErr
+		}
+
+		for _, member := range targetMembers {
+			if !targeted[member.Name] {
+				members = append(members, member)
+				targeted[member.Name] = true
+			}
+		}
+	}
+
+	dis := disruptedMembers{
+		members:    members,
+		cluster:    clus,
+		disruption: strings.ToLower(step.Disruption),
+	}
+
+	var disErr error
+	switch dis.disruption {
+	case "destruction":
+		disErr = iter.runner.Disruptor.Destroy(members)
+	case "reboot":
+		disErr = iter.runner.Disruptor.Stop(members)
+	case "partition":
+		disErr = iter.runner.Hooks.Partition(members, clus)
+	case "degradation":
+		disErr = iter.runner.Hooks.Degrade(members, clus, hooks.Degradation{
+			Latency:    iter.runner.Conf.Tests.DegradationLatency,
+			Jitter:     iter.runner.Conf.Tests.DegradationJitter,
+			PacketLoss: iter.runner.Conf.Tests.DegradationPacketLoss,
+		})
+		dis.degradedSince = time.Now()
+	case "postgres_kill":
+		disErr = iter.runner.Hooks.KillPostgres(members, clus)
+	case "patroni_kill":
+		disErr = iter.runner.Hooks.KillPatroni(members, clus)
+	case "postgres_restart":
+		for _, member := range members {
+			disErr = iter.pClient.Restart(member, patroni.RestartOptions{
+				RestartPending: iter.runner.Conf.Tests.RestartPendingOnly,
+				Role:           iter.runner.Conf.Tests.RestartRole,
+			})
+			if disErr != nil {
+				break
+			}
+		}
+	case "reinitialization":
+		for _, member := range members {
+			var reinit patroni.ReinitializeResult
+			reinit, disErr = iter.pClient.Reinitialize(member)
+			if disErr != nil {
+				break
+			}
+			dis.reinits = append(dis.reinits, reinit)
+		}
+	default:
+		return errors.New(fmt.Sprintf("Unsupported disruption \"%s\"", step.Disruption))
+	}
+	if disErr != nil {
+		//Hooks can fail after they partitioned or degraded some of the members, so those are restored anyway
+		if dis.disruption == "partition" || dis.disruption == "degradation" {
+			iter.disrupted = append(iter.disrupted, dis)
+		}
+		return disErr
+	}
+
+	iter.disrupted = append(iter.disrupted, dis)
+	return nil
+}
+
+/*
+restore undoes the disruptions that were not restored yet, the latest one first.
+Killed processes are expected to be restarted on their own while restarts complete by themselves,
+so there is nothing to undo for them. Reinitializations also complete by themselves, but restoring
+them waits for the reinitialized members to stream again without lag.
+*/
+func (iter *iteration) restore() error {
+	for len(iter.disrupted) > 0 {
+		dis := iter.disrupted[len(iter.disrupted)-1]
+
+		var resErr error
+		switch dis.disruption {
+		case "destruction":
+			resErr = iter.runner.Disruptor.Recreate(dis.members)
+		case "reboot":
+			resErr = iter.runner.Disruptor.Start(dis.members)
+		case "partition":
+			resErr = iter.runner.Hooks.Heal(dis.members, dis.cluster)
+		case "degradation":
+			iter.degradedWindows = append(iter.degradedWindows, measure.Window{Start: dis.degradedSince, End: time.Now()})
+			resErr = iter.runner.Hooks.RestoreNetwork(dis.members, dis.cluster)
+		case "reinitialization":
+			for _, reinit := range dis.reinits {
+				beginning := time.Now()
+				resErr = iter.pClient.WaitForStreaming(reinit, iter.runner.Conf.Tests.ReinitRecoverTimeout)
+				if resErr != nil {
+					break
+				}
+				iter.runner.Log.Infof("Server \"%s\" was streaming without lag after waiting %s for its reinitialization", reinit.Member.Name, time.Now().Sub(beginning).String())
+			}
+		}
+		if resErr != nil {
+			return errors.New(fmt.Sprintf("Error restoring servers \"%s\": %s", memberNames(dis.members), resErr.Error()))
+		}
+
+		iter.disrupted = iter.disrupted[:len(iter.disrupted)-1]
+	}
+
+	return nil
+}
+
+/*
+setSyncMode changes the synchronous mode of the cluster and starts a new measurement period for it.
+*/
+func (iter *iteration) setSyncMode(mode patroni.SyncMode) error {
+	previous, modeErr := iter.pClient.GetSyncMode()
+	if modeErr != nil {
+		return modeErr
+	}
+
+	if previous == mode {
+		return nil
+	}
+
+	setErr := iter.pClient.SetSyncMode(mode)
+	if setErr != nil {
+		return setErr
+	}
+
+	iter.syncModes.change(previous, mode, iter.probe.Snapshot())
+	return nil
+}
+
+func (iter *iteration) assert(assertConf *config.ScenarioAssertConfig) error {
+	if assertConf.LeaderChanged != nil {
+		clus, clusErr := iter.pClient.GetCluster()
+		if clusErr != nil {
+			return clusErr
+		}
+
+		initialLeader := iter.initialCluster.GetLeader().Name
+		leader := clus.GetLeader().Name
+		if *assertConf.LeaderChanged && leader == initialLeader {
+			return errors.New(fmt.Sprintf("Leader was expected to change, but it is still \"%s\"", leader))
+		}
+		if !*assertConf.LeaderChanged && leader != initialLeader {
+			return errors.New(fmt.Sprintf("Leader was expected to remain \"%s\", but it is now \"%s\"", initialLeader, leader))
+		}
+	}
+
+	meas := iter.probe.Snapshot()
+	if assertConf.MaxLostOps != nil && meas.LostOps > *assertConf.MaxLostOps {
+		return errors.New(fmt.Sprintf("%d ops were lost while at most %d were expected to be", meas.LostOps, *assertConf.MaxLostOps))
+	}
+
+	if assertConf.MaxGhostOps != nil && meas.GhostOps > *assertConf.MaxGhostOps {
+		return errors.New(fmt.Sprintf("%d ghost ops were committed while at most %d were expected to be", meas.GhostOps, *assertConf.MaxGhostOps))
+	}
+
+	if assertConf.MaxOutage.Nanoseconds() > 0 && meas.Outages.Longest > assertConf.MaxOutage {
+		return errors.New(fmt.Sprintf("Longest outage lasted %s while it was expected to last at most %s", meas.Outages.Longest.String(), assertConf.MaxOutage.String()))
+	}
+
+	iter.runner.Log.Infof("Assertions passed")
+	return nil
+}
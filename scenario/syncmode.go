@@ -0,0 +1,101 @@
+package scenario
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/measure"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/patroni"
+)
+
+/*
+SyncModePeriod holds the ops that were lost or ghosted while the cluster was in a given synchronous mode.
+*/
+type SyncModePeriod struct {
+	Mode     string
+	Window   measure.Window
+	LostOps  int64
+	GhostOps int64
+}
+
+/*
+syncModeTracker splits the measurements of a scenario into periods, one for each
+synchronous mode change.
+*/
+type syncModeTracker struct {
+	started  bool
+	current  patroni.SyncMode
+	since    time.Time
+	baseline measure.Measurements
+	periods  []SyncModePeriod
+}
+
+func (tracker *syncModeTracker) change(previous patroni.SyncMode, mode patroni.SyncMode, snapshot measure.Measurements) {
+	if !tracker.started {
+		tracker.started = true
+		tracker.since = snapshot.Window.Start
+		if tracker.since.IsZero() {
+			tracker.since = time.Now()
+		}
+	}
+
+	tracker.periods = append(tracker.periods, SyncModePeriod{
+		Mode:     previous.String(),
+		Window:   measure.Window{Start: tracker.since, End: snapshot.Window.End},
+		LostOps:  snapshot.LostOps - tracker.baseline.LostOps,
+		GhostOps: snapshot.GhostOps - tracker.baseline.GhostOps,
+	})
+
+	tracker.current = mode
+	tracker.since = snapshot.Window.End
+	tracker.baseline = snapshot
+}
+
+/*
+close ends the last period with the final measurements and returns all the periods.
+No period is returned if the synchronous mode was never changed.
+*/
+func (tracker *syncModeTracker) close(meas measure.Measurements) []SyncModePeriod {
+	if !tracker.started {
+		return nil
+	}
+
+	return append(tracker.periods, SyncModePeriod{
+		Mode:     tracker.current.String(),
+		Window:   measure.Window{Start: tracker.since, End: meas.Window.End},
+		LostOps:  meas.LostOps - tracker.baseline.LostOps,
+		GhostOps: meas.GhostOps - tracker.baseline.GhostOps,
+	})
+}
+
+func syncModeLines(meas *measure.Measurements, periods []SyncModePeriod) []string {
+	modes := []string{}
+	byMode := map[string][]SyncModePeriod{}
+	for _, period := range periods {
+		if _, ok := byMode[period.Mode]; !ok {
+			modes = append(modes, period.Mode)
+		}
+		byMode[period.Mode] = append(byMode[period.Mode], period)
+	}
+
+	lines := []string{}
+	for _, mode := range modes {
+		lostOps := int64(0)
+		ghostOps := int64(0)
+		windows := []measure.Window{}
+		for _, period := range byMode[mode] {
+			lostOps += period.LostOps
+			ghostOps += period.GhostOps
+			windows = append(windows, period.Window)
+		}
+
+		perf := meas.Performance(windows...)
+		lines = append(lines, fmt.Sprintf("While the cluster was in %s:", mode))
+		lines = append(lines, fmt.Sprintf("\tLost Ops: %d", lostOps))
+		lines = append(lines, fmt.Sprintf("\tGhost Ops: %d", ghostOps))
+		lines = append(lines, "\t"+strings.Join(perf.Lines(), "\n\t"))
+	}
+
+	return lines
+}
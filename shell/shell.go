@@ -0,0 +1,73 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/command"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/config"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/logger"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/patroni"
+)
+
+type TemplateData struct {
+	Name string
+	Host string
+}
+
+type Disruptor struct {
+	Conf *config.ShellConfig
+	Log  logger.Logger
+}
+
+func (dis *Disruptor) runOnMember(action string, tmpl string, member patroni.PatroniMember) error {
+	cmdLine, renderErr := command.Render(action, tmpl, TemplateData{Name: member.Name, Host: member.Host})
+	if renderErr != nil {
+		return renderErr
+	}
+
+	return command.RunShell(dis.Conf.Timeout, dis.Log, dis.Conf.Shell, cmdLine)
+}
+
+func (dis *Disruptor) runOnMembers(action string, result string, tmpl string, members []patroni.PatroniMember) error {
+	if tmpl == "" {
+		return errors.New(fmt.Sprintf("No command is configured for the \"%s\" action of the shell disruption backend", action))
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(members))
+	for idx, member := range members {
+		wg.Add(1)
+		go func(idx int, member patroni.PatroniMember) {
+			defer wg.Done()
+			errs[idx] = dis.runOnMember(action, tmpl, member)
+		}(idx, member)
+	}
+	wg.Wait()
+
+	for idx, member := range members {
+		if errs[idx] != nil {
+			return errors.New(fmt.Sprintf("Error running the \"%s\" command on server \"%s\": %s", action, member.Name, errs[idx].Error()))
+		}
+		dis.Log.Infof("Server \"%s\" has been %s", member.Name, result)
+	}
+
+	return nil
+}
+
+func (dis *Disruptor) Destroy(members []patroni.PatroniMember) error {
+	return dis.runOnMembers("destroy", "destroyed", dis.Conf.Destroy, members)
+}
+
+func (dis *Disruptor) Recreate(members []patroni.PatroniMember) error {
+	return dis.runOnMembers("recreate", "recreated", dis.Conf.Recreate, members)
+}
+
+func (dis *Disruptor) Stop(members []patroni.PatroniMember) error {
+	return dis.runOnMembers("stop", "stopped", dis.Conf.Stop, members)
+}
+
+func (dis *Disruptor) Start(members []patroni.PatroniMember) error {
+	return dis.runOnMembers("start", "started", dis.Conf.Start, members)
+}
@@ -2,13 +2,17 @@ package terraform
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
+	"strings"
 
 	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/config"
 	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/logger"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/patroni"
 
 	"github.com/hashicorp/terraform-exec/tfexec"
 	yaml "gopkg.in/yaml.v2"
@@ -24,20 +28,30 @@ type ServersStatus struct {
 	Cluster []ServerStatus
 }
 
-func (status *ServersStatus) SetStatus(name string, exists bool, running bool) {
+func (status *ServersStatus) SetStatus(names []string, exists bool, running bool) error {
+	if len(names) == 0 {
+		return errors.New("No server was given to change the status of")
+	}
+
+	found := map[string]bool{}
 	for idx, _ := range status.Cluster {
-		if name == "" {
-			status.Cluster[idx].Exists = exists
-			status.Cluster[idx].Running = running
-			continue
+		for _, name := range names {
+			if status.Cluster[idx].Name == name {
+				status.Cluster[idx].Exists = exists
+				status.Cluster[idx].Running = running
+				found[name] = true
+				break
+			}
 		}
+	}
 
-		if status.Cluster[idx].Name == name {
-			status.Cluster[idx].Exists = exists
-			status.Cluster[idx].Running = running
-			break
+	for _, name := range names {
+		if !found[name] {
+			return errors.New(fmt.Sprintf("Server \"%s\" is not in the cluster file", name))
 		}
 	}
+
+	return nil
 }
 
 func readServerStatus(fPath string) (ServersStatus, error) {
@@ -61,7 +75,7 @@ func persistServersStatus(fPath string, status ServersStatus) error {
 	return os.WriteFile(fPath, data, 0644)
 }
 
-func SetServerStatus(name string, exists bool, running bool, conf *config.TerraformConfig, log logger.Logger) error {
+func SetServerStatus(names []string, exists bool, running bool, conf *config.TerraformConfig, log logger.Logger) error {
 	clusPath := path.Join(conf.Directory, conf.ClusterFile)
 	
 	status, readErr := readServerStatus(clusPath)
@@ -69,7 +83,10 @@ func SetServerStatus(name string, exists bool, running bool, conf *config.Terraf
 		return readErr
 	}
 
-	status.SetStatus(name, exists, running)
+	setErr := status.SetStatus(names, exists, running)
+	if setErr != nil {
+		return setErr
+	}
 
 	perErr := persistServersStatus(clusPath, status)
 	if perErr != nil {
@@ -105,11 +122,40 @@ func SetServerStatus(name string, exists bool, running bool, conf *config.Terraf
 		action = "stopped"
 	}
 
-	if name != "" {
-		log.Infof("Server \"%s\" has been %s", name, action)
+	if len(names) == 1 {
+		log.Infof("Server \"%s\" has been %s", names[0], action)
 	} else {
-		log.Infof("All servers have been %s", action)
+		log.Infof("Servers \"%s\" have been %s", strings.Join(names, "\", \""), action)
 	}
 
 	return nil
+}
+
+type Disruptor struct {
+	Conf *config.TerraformConfig
+	Log  logger.Logger
+}
+
+func memberNames(members []patroni.PatroniMember) []string {
+	names := []string{}
+	for _, member := range members {
+		names = append(names, member.Name)
+	}
+	return names
+}
+
+func (dis *Disruptor) Destroy(members []patroni.PatroniMember) error {
+	return SetServerStatus(memberNames(members), false, true, dis.Conf, dis.Log)
+}
+
+func (dis *Disruptor) Recreate(members []patroni.PatroniMember) error {
+	return SetServerStatus(memberNames(members), true, true, dis.Conf, dis.Log)
+}
+
+func (dis *Disruptor) Stop(members []patroni.PatroniMember) error {
+	return SetServerStatus(memberNames(members), true, false, dis.Conf, dis.Log)
+}
+
+func (dis *Disruptor) Start(members []patroni.PatroniMember) error {
+	return SetServerStatus(memberNames(members), true, true, dis.Conf, dis.Log)
 }
\ No newline at end of file
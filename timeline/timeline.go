@@ -0,0 +1,256 @@
+package timeline
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/config"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/logger"
+	"github.com/Ferlab-Ste-Justine/postgres-chaos-analyst/patroni"
+)
+
+/*
+Event is a change in the role, state, timeline or lag of a member, as observed by the recorder.
+*/
+type Event struct {
+	Time   time.Time
+	Member string
+	Change string
+}
+
+func (ev *Event) String() string {
+	return fmt.Sprintf("%s: \"%s\" %s", ev.Time.Format("15:04:05.000"), ev.Member, ev.Change)
+}
+
+/*
+Timeline is the history of the cluster state during a run, along with the metrics derived from it.
+Periods during which the cluster state could not be retrieved are reported as unobserved
+rather than being attributed to any state.
+*/
+type Timeline struct {
+	Start              time.Time
+	End                time.Time
+	Events             []Event
+	WithoutLeader      time.Duration
+	WithoutSyncStandby time.Duration
+	Unobserved         time.Duration
+	TimelineBumps      int64
+}
+
+/*
+Recorded indicates whether the timeline was recorded at all, as the recorder can be disabled.
+*/
+func (tl *Timeline) Recorded() bool {
+	return !tl.Start.IsZero()
+}
+
+func (tl *Timeline) String() string {
+	lines := []string{
+		"Cluster Timeline:",
+		fmt.Sprintf("\tTime Without Leader: %s", tl.WithoutLeader.String()),
+		fmt.Sprintf("\tTime Without Sync Standby: %s", tl.WithoutSyncStandby.String()),
+		fmt.Sprintf("\tUnobserved Time: %s", tl.Unobserved.String()),
+		fmt.Sprintf("\tTimeline Bumps: %d", tl.TimelineBumps),
+		"\tEvents:",
+	}
+
+	for _, ev := range tl.Events {
+		lines = append(lines, "\t\t"+ev.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func isUp(member patroni.PatroniMember) bool {
+	return member.State == "running" || member.State == "streaming"
+}
+
+func describeLag(lag patroni.PatroniMemberLag) string {
+	if lag < patroni.PatroniMemberLag(0) {
+		return "unknown"
+	}
+
+	return fmt.Sprintf("%d", lag)
+}
+
+/*
+lagLevel classifies the lag of a member relative to the threshold, so that only the
+changes that cross the threshold are recorded rather than every fluctuation of the lag.
+*/
+func lagLevel(lag patroni.PatroniMemberLag, threshold int64) int {
+	if lag < patroni.PatroniMemberLag(0) {
+		return -1
+	}
+
+	if int64(lag) <= threshold {
+		return 0
+	}
+
+	return 1
+}
+
+func describeLagChange(previous patroni.PatroniMemberLag, current patroni.PatroniMemberLag, threshold int64) string {
+	switch lagLevel(current, threshold) {
+	case -1:
+		return fmt.Sprintf("lag became unknown, from %s", describeLag(previous))
+	case 0:
+		return fmt.Sprintf("lag went down to %s, within the threshold of %d", describeLag(current), threshold)
+	default:
+		return fmt.Sprintf("lag went up to %s, above the threshold of %d", describeLag(current), threshold)
+	}
+}
+
+func memberChanges(previous patroni.PatroniMember, current patroni.PatroniMember, lagThreshold int64) []string {
+	changes := []string{}
+	if previous.Role != current.Role {
+		changes = append(changes, fmt.Sprintf("role changed from %s to %s", previous.Role, current.Role))
+	}
+
+	if previous.State != current.State {
+		changes = append(changes, fmt.Sprintf("state changed from %s to %s", previous.State, current.State))
+	}
+
+	if previous.Timeline != current.Timeline {
+		changes = append(changes, fmt.Sprintf("timeline changed from %d to %d", previous.Timeline, current.Timeline))
+	}
+
+	if lagLevel(previous.Lag, lagThreshold) != lagLevel(current.Lag, lagThreshold) {
+		changes = append(changes, describeLagChange(previous.Lag, current.Lag, lagThreshold))
+	}
+
+	return changes
+}
+
+type recorder struct {
+	timeline       Timeline
+	lagThreshold   int64
+	observed       bool
+	lastPoll       time.Time
+	hadLeader      bool
+	hadSyncStandby bool
+	leaderTimeline int64
+	members        map[string]patroni.PatroniMember
+}
+
+func (rec *recorder) elapse(now time.Time) {
+	elapsed := now.Sub(rec.lastPoll)
+	if !rec.observed {
+		rec.timeline.Unobserved += elapsed
+	} else {
+		if !rec.hadLeader {
+			rec.timeline.WithoutLeader += elapsed
+		}
+		if !rec.hadSyncStandby {
+			rec.timeline.WithoutSyncStandby += elapsed
+		}
+	}
+	rec.lastPoll = now
+}
+
+func (rec *recorder) observe(cluster patroni.PatroniCluster, now time.Time) {
+	rec.elapse(now)
+
+	members := map[string]patroni.PatroniMember{}
+	for _, member := range cluster.Members {
+		members[member.Name] = member
+
+		previous, ok := rec.members[member.Name]
+		if !ok {
+			rec.timeline.Events = append(rec.timeline.Events, Event{
+				Time:   now,
+				Member: member.Name,
+				Change: fmt.Sprintf("appeared as %s in state %s on timeline %d with lag %s", member.Role, member.State, member.Timeline, describeLag(member.Lag)),
+			})
+			continue
+		}
+
+		for _, change := range memberChanges(previous, member, rec.lagThreshold) {
+			rec.timeline.Events = append(rec.timeline.Events, Event{Time: now, Member: member.Name, Change: change})
+		}
+	}
+
+	for name := range rec.members {
+		if _, ok := members[name]; !ok {
+			rec.timeline.Events = append(rec.timeline.Events, Event{Time: now, Member: name, Change: "disappeared"})
+		}
+	}
+	rec.members = members
+
+	leader := cluster.GetLeader()
+	syncStandby := cluster.GetSyncStandby()
+	rec.hadLeader = leader.Name != "" && isUp(leader)
+	rec.hadSyncStandby = syncStandby.Name != "" && isUp(syncStandby)
+
+	if rec.hadLeader {
+		if rec.leaderTimeline != 0 && leader.Timeline > rec.leaderTimeline {
+			rec.timeline.TimelineBumps += leader.Timeline - rec.leaderTimeline
+		}
+		if leader.Timeline > rec.leaderTimeline {
+			rec.leaderTimeline = leader.Timeline
+		}
+	}
+
+	rec.observed = true
+}
+
+func (rec *recorder) miss(now time.Time) {
+	rec.elapse(now)
+	rec.observed = false
+}
+
+/*
+Record polls the state of the cluster at the poll interval until the done channel is closed
+and then returns the recorded timeline. No timeline is recorded if the interval is not positive.
+*/
+func Record(patrConf *config.PatroniClientConfig, tlConf *config.TimelineConfig, done <-chan struct{}, log logger.Logger) <-chan Timeline {
+	resCh := make(chan Timeline, 1)
+	interval := tlConf.PollInterval
+
+	go func() {
+		if interval <= 0 {
+			<-done
+			resCh <- Timeline{}
+			return
+		}
+
+		rec := recorder{
+			timeline:     Timeline{Start: time.Now()},
+			lagThreshold: tlConf.LagThreshold,
+			lastPoll:     time.Now(),
+			members:      map[string]patroni.PatroniMember{},
+		}
+
+		pClient, pClientErr := patroni.NewPatroniClient(patrConf, log)
+		if pClientErr != nil {
+			log.Errorf("Timeline recorder could not create its patroni client: %s", pClientErr.Error())
+			<-done
+			resCh <- Timeline{}
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			cluster, clusterErr := pClient.GetCluster()
+			if clusterErr != nil {
+				log.Debugf("Timeline recorder could not retrieve the cluster: %s", clusterErr.Error())
+				rec.miss(time.Now())
+			} else {
+				rec.observe(cluster, time.Now())
+			}
+
+			select {
+			case <-done:
+				rec.elapse(time.Now())
+				rec.timeline.End = rec.lastPoll
+				resCh <- rec.timeline
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return resCh
+}